@@ -6,11 +6,25 @@ import (
 	"github.com/ansible-semaphore/semaphore/db"
 	"github.com/ansible-semaphore/semaphore/util"
 	"github.com/gorilla/context"
+	"github.com/gorilla/securecookie"
 	"net/http"
 	"strings"
 	"time"
 )
 
+// cookieIntValue returns integer value of the cookie. JSON serializer
+// decodes numbers as float64, gob serializer keeps int.
+func cookieIntValue(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
 func authenticationHandler(w http.ResponseWriter, r *http.Request) bool {
 	var userID int
 
@@ -38,20 +52,19 @@ func authenticationHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 
 		value := make(map[string]interface{})
-		if err = util.Cookie.Decode("semaphore", cookie.Value, &value); err != nil {
+		if err = securecookie.DecodeMulti("semaphore", cookie.Value, &value, util.CookieCodecs...); err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
 			return false
 		}
 
-		user, ok := value["user"]
-		sessionVal, okSession := value["session"]
+		user, ok := cookieIntValue(value["user"])
+		sessionID, okSession := cookieIntValue(value["session"])
 		if !ok || !okSession {
 			w.WriteHeader(http.StatusUnauthorized)
 			return false
 		}
 
-		userID = user.(int)
-		sessionID := sessionVal.(int)
+		userID = user
 
 		// fetch session
 		session, err := helpers.Store(r).GetSession(userID, sessionID)
@@ -61,8 +74,8 @@ func authenticationHandler(w http.ResponseWriter, r *http.Request) bool {
 			return false
 		}
 
-		if time.Since(session.LastActive).Hours() > 7*24 {
-			// more than week old unused session
+		if time.Since(session.LastActive) > util.Config.GetCookieIdleTimeout() {
+			// unused session
 			// destroy.
 			if err := helpers.Store(r).ExpireSession(userID, sessionID); err != nil {
 				// it is internal error, it doesn't concern the user
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ansible-semaphore/semaphore/db"
+	"github.com/ansible-semaphore/semaphore/db/bolt"
+	"github.com/ansible-semaphore/semaphore/util"
+	"github.com/gorilla/context"
+	"github.com/gorilla/securecookie"
+)
+
+func TestAuthenticationSessionIdleTimeout(t *testing.T) {
+	oldConfig, oldCodecs := util.Config, util.CookieCodecs
+	t.Cleanup(func() {
+		util.Config, util.CookieCodecs = oldConfig, oldCodecs
+	})
+
+	util.Config = &util.ConfigType{CookieIdleTimeoutSeconds: 3600}
+	codec := securecookie.New(securecookie.GenerateRandomKey(32), nil)
+	util.CookieCodecs = []securecookie.Codec{codec}
+
+	store := bolt.CreateTestStore()
+
+	user, err := store.CreateUserWithoutPassword(db.User{Username: "user", Name: "User", Email: "user@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticate := func(lastActive time.Time) (db.Session, int) {
+		session, err := store.CreateSession(db.Session{
+			UserID:     user.ID,
+			Created:    lastActive,
+			LastActive: lastActive,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := codec.Encode("semaphore", map[string]interface{}{
+			"user":    user.ID,
+			"session": session.ID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user", nil)
+		req.AddCookie(&http.Cookie{Name: "semaphore", Value: encoded})
+		context.Set(req, "store", store)
+		defer context.Clear(req)
+
+		rr := httptest.NewRecorder()
+		if !authenticationHandler(rr, req) {
+			return session, rr.Code
+		}
+		return session, http.StatusOK
+	}
+
+	if _, code := authenticate(time.Now().Add(-30 * time.Minute)); code != http.StatusOK {
+		t.Errorf("Active session must be accepted, got %d", code)
+	}
+
+	session, code := authenticate(time.Now().Add(-2 * time.Hour))
+	if code != http.StatusUnauthorized {
+		t.Errorf("Idle session must be rejected, got %d", code)
+	}
+
+	session, err = store.GetSession(user.ID, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.Expired {
+		t.Error("Idle session must be expired")
+	}
+}
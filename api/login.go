@@ -2,14 +2,18 @@ package api
 
 import (
 	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -25,26 +29,100 @@ import (
 	"github.com/ansible-semaphore/semaphore/util"
 )
 
+var (
+	authSlotsLock  sync.Mutex
+	authSlots      chan struct{}
+	authSlotsLimit int
+)
+
+// authSlotTimeout is how long authentication waits for a free slot
+var authSlotTimeout = 10 * time.Second
+
+var errAuthSlotTimeout = errors.New("too many concurrent LDAP/OIDC authentications")
+
+// getAuthSlots returns semaphore sized by current MaxConcurrentAuth or nil if
+// authentications are not limited. Semaphore is recreated if the limit is changed
+// by config reload, slots of the previous one are released to it.
+func getAuthSlots() chan struct{} {
+	limit := util.Config.GetMaxConcurrentAuth()
+
+	authSlotsLock.Lock()
+	defer authSlotsLock.Unlock()
+
+	if limit != authSlotsLimit {
+		authSlotsLimit = limit
+		authSlots = nil
+		if limit > 0 {
+			authSlots = make(chan struct{}, limit)
+		}
+	}
+
+	return authSlots
+}
+
+// acquireAuthSlot waits until number of in-flight LDAP/OIDC
+// authentications is below MaxConcurrentAuth. Returned function
+// must be called to release the slot. Error is returned if no slot
+// is released during authSlotTimeout.
+func acquireAuthSlot() (release func(), err error) {
+	slots := getAuthSlots()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	timer := time.NewTimer(authSlotTimeout)
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-timer.C:
+		return nil, errAuthSlotTimeout
+	}
+}
+
+// dialLDAP connects to the first available LDAP server
+func dialLDAP() (l *ldap.Conn, err error) {
+	err = fmt.Errorf("LDAP server is not set")
+
+	for _, server := range util.Config.GetLdapServers() {
+		if util.Config.LdapNeedTLS {
+			l, err = ldap.DialTLS("tcp", server, &tls.Config{
+				InsecureSkipVerify: true,
+			})
+		} else {
+			l, err = ldap.Dial("tcp", server)
+		}
+
+		if err == nil {
+			return
+		}
+
+		log.Warn("Can't connect to LDAP server " + server + ": " + err.Error())
+	}
+
+	return
+}
+
 func tryFindLDAPUser(username, password string) (*db.User, error) {
 	if !util.Config.LdapEnable {
 		return nil, fmt.Errorf("LDAP not configured")
 	}
 
-	var l *ldap.Conn
-	var err error
-	if util.Config.LdapNeedTLS {
-		l, err = ldap.DialTLS("tcp", util.Config.LdapServer, &tls.Config{
-			InsecureSkipVerify: true,
-		})
-	} else {
-		l, err = ldap.Dial("tcp", util.Config.LdapServer)
-	}
-
+	l, err := dialLDAP()
 	if err != nil {
 		return nil, err
 	}
 	defer l.Close()
 
+	if util.Config.LdapUseStartTLS {
+		if err = l.StartTLS(&tls.Config{
+			InsecureSkipVerify: true,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
 	// First bind with a read only user
 	if err = l.Bind(util.Config.LdapBindDN, util.Config.LdapBindPassword); err != nil {
 		return nil, err
@@ -188,6 +266,10 @@ func loginByLDAP(store db.Store, ldapUser db.User) (user db.User, err error) {
 	user, err = store.GetUserByLoginOrEmail(ldapUser.Username, ldapUser.Email)
 
 	if err == db.ErrNotFound {
+		if !util.Config.IsEmailDomainAllowed(ldapUser.Email) {
+			err = fmt.Errorf("email domain of LDAP user '%s' is not allowed", ldapUser.Username)
+			return
+		}
 		user, err = store.CreateUserWithoutPassword(ldapUser)
 	}
 
@@ -209,6 +291,8 @@ type loginMetadataOidcProvider struct {
 type loginMetadata struct {
 	OidcProviders     []loginMetadataOidcProvider `json:"oidc_providers"`
 	LoginWithPassword bool                        `json:"login_with_password"`
+	BannerTitle       string                      `json:"banner_title,omitempty"`
+	BannerText        string                      `json:"banner_text,omitempty"`
 }
 
 // nolint: gocyclo
@@ -218,6 +302,7 @@ func login(w http.ResponseWriter, r *http.Request) {
 			OidcProviders:     make([]loginMetadataOidcProvider, len(util.Config.OidcProviders)),
 			LoginWithPassword: !util.Config.PasswordLoginDisable,
 		}
+		config.BannerTitle, config.BannerText = util.Config.GetLoginBanner()
 		i := 0
 		for k, v := range util.Config.OidcProviders {
 			config.OidcProviders[i] = loginMetadataOidcProvider{
@@ -256,7 +341,15 @@ func login(w http.ResponseWriter, r *http.Request) {
 	var ldapUser *db.User
 
 	if util.Config.LdapEnable {
+		var release func()
+		release, err = acquireAuthSlot()
+		if err != nil {
+			log.Warn(err.Error())
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
 		ldapUser, err = tryFindLDAPUser(login.Auth, login.Password)
+		release()
 		if err != nil {
 			log.Warn(err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
@@ -303,28 +396,97 @@ func logout(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func getOidcProvider(id string, ctx context.Context) (*oidc.Provider, *oauth2.Config, error) {
-	provider, ok := util.Config.OidcProviders[id]
-	if !ok {
-		return nil, nil, fmt.Errorf("No such provider: %s", id)
+type cachedOidcProvider struct {
+	provider *oidc.Provider
+	expires  time.Time
+}
+
+// oidcProviders caches providers because each provider keeps its own JWKS cache.
+// Cache entries expire after OidcJWKSCacheTTLSeconds to pick up rotated keys.
+// Entries are keyed by oidcProviderCacheKey, so providers changed by config reload
+// are not served from the cache.
+var oidcProviders = make(map[string]cachedOidcProvider)
+var oidcProvidersLock sync.Mutex
+
+// oidcProviderCacheKey returns key of the provider which changes
+// with settings used to create oidc.Provider
+func oidcProviderCacheKey(id string, provider util.OidcProvider) string {
+	settings, _ := json.Marshal(struct {
+		AutoDiscovery string
+		Issuer        string
+		Endpoint      interface{}
+	}{provider.AutoDiscovery, provider.GetExpectedIssuer(), provider.Endpoint})
+
+	return id + "\n" + string(settings)
+}
+
+func newOidcProvider(provider util.OidcProvider, ctx context.Context) (*oidc.Provider, error) {
+	if len(provider.AutoDiscovery) > 0 {
+		return oidc.NewProvider(ctx, provider.AutoDiscovery)
 	}
+
 	config := oidc.ProviderConfig{
-		IssuerURL:   provider.Endpoint.IssuerURL,
+		IssuerURL:   provider.GetExpectedIssuer(),
 		AuthURL:     provider.Endpoint.AuthURL,
 		TokenURL:    provider.Endpoint.TokenURL,
 		UserInfoURL: provider.Endpoint.UserInfoURL,
 		JWKSURL:     provider.Endpoint.JWKSURL,
 		Algorithms:  provider.Endpoint.Algorithms,
 	}
-	oidcProvider := config.NewProvider(ctx)
-	var err error
-	if len(provider.AutoDiscovery) > 0 {
-		oidcProvider, err = oidc.NewProvider(ctx, provider.AutoDiscovery)
-		if err != nil {
-			return nil, nil, err
+	return config.NewProvider(ctx), nil
+}
+
+func getCachedOidcProvider(id string, provider util.OidcProvider, ctx context.Context) (*oidc.Provider, error) {
+	ttl := util.Config.GetOidcJWKSCacheTTL()
+	if ttl <= 0 {
+		return newOidcProvider(provider, ctx)
+	}
+
+	key := oidcProviderCacheKey(id, provider)
+
+	oidcProvidersLock.Lock()
+	cached, ok := oidcProviders[key]
+	oidcProvidersLock.Unlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		return cached.provider, nil
+	}
+
+	// discovery is made without the lock, so slow provider doesn't block logins by others
+	oidcProvider, err := newOidcProvider(provider, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProvidersLock.Lock()
+	defer oidcProvidersLock.Unlock()
+
+	now := time.Now()
+	for k, p := range oidcProviders {
+		if !now.Before(p.expires) {
+			delete(oidcProviders, k)
 		}
 	}
 
+	oidcProviders[key] = cachedOidcProvider{
+		provider: oidcProvider,
+		expires:  now.Add(ttl),
+	}
+
+	return oidcProvider, nil
+}
+
+func getOidcProvider(id string, ctx context.Context) (*oidc.Provider, *oauth2.Config, error) {
+	provider, ok := util.Config.OidcProviders[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("No such provider: %s", id)
+	}
+
+	oidcProvider, err := getCachedOidcProvider(id, provider, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	oauthConfig := oauth2.Config{
 		Endpoint:     oidcProvider.Endpoint(),
 		ClientID:     provider.ClientID,
@@ -347,34 +509,112 @@ func getOidcProvider(id string, ctx context.Context) (*oidc.Provider, *oauth2.Co
 
 func oidcLogin(w http.ResponseWriter, r *http.Request) {
 	pid := mux.Vars(r)["provider"]
-	ctx := context.Background()
+	ctx := oidc.ClientContext(context.Background(), util.BuildHTTPClient(nil))
 	_, oauth, err := getOidcProvider(pid, ctx)
 	if err != nil {
 		log.Error(err.Error())
 		http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
 		return
 	}
-	state := generateStateOauthCookie(w)
-	u := oauth.AuthCodeURL(state)
+	provider := util.Config.OidcProviders[pid]
+	crossSite := provider.ResponseMode == util.OidcResponseModeFormPost
+	state := generateStateOauthCookie(w, crossSite)
+	opts := oidcAuthCodeOptions(provider)
+
+	if provider.UsePKCE {
+		var verifier string
+		verifier, err = generatePKCEVerifierCookie(w, crossSite)
+		if err != nil {
+			log.Error(err.Error())
+			http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
+			return
+		}
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", pkceChallenge(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+
+	u := oauth.AuthCodeURL(state, opts...)
 	http.Redirect(w, r, u, http.StatusTemporaryRedirect)
 }
 
-func generateStateOauthCookie(w http.ResponseWriter) string {
+// oidcAuthCodeOptions returns response_mode parameter of authorization request.
+// oauth2 sends response_type=code, the only supported response type.
+func oidcAuthCodeOptions(provider util.OidcProvider) (opts []oauth2.AuthCodeOption) {
+	if provider.ResponseMode != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("response_mode", provider.ResponseMode))
+	}
+	return
+}
+
+// generateStateOauthCookie sets cookie with OAuth state. Cookie must be cross-site
+// if IdP posts the response (response_mode=form_post).
+func generateStateOauthCookie(w http.ResponseWriter, crossSite bool) string {
 	expiration := time.Now().Add(365 * 24 * time.Hour)
 
 	b := make([]byte, 16)
 	rand.Read(b)
 	oauthState := base64.URLEncoding.EncodeToString(b)
 	cookie := http.Cookie{Name: "oauthstate", Value: oauthState, Expires: expiration}
+	if crossSite {
+		cookie.SameSite = http.SameSiteNoneMode
+		cookie.Secure = true
+	}
 	http.SetCookie(w, &cookie)
 
 	return oauthState
 }
 
+// generatePKCEVerifierCookie sets cookie with PKCE code verifier (RFC 7636)
+// which is sent to the token endpoint when the code is exchanged.
+func generatePKCEVerifierCookie(w http.ResponseWriter, crossSite bool) (string, error) {
+	b := make([]byte, 32)
+	if _, err := cryptorand.Read(b); err != nil {
+		return "", err
+	}
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+
+	cookie := http.Cookie{
+		Name:     "oauthpkce",
+		Value:    verifier,
+		Expires:  time.Now().Add(time.Hour),
+		HttpOnly: true,
+	}
+	if crossSite {
+		cookie.SameSite = http.SameSiteNoneMode
+		cookie.Secure = true
+	}
+	http.SetCookie(w, &cookie)
+
+	return verifier, nil
+}
+
+// pkceChallenge returns S256 code challenge of the verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 type oidcClaimResult struct {
-	username string
-	name     string
-	email    string
+	login         string
+	username      string
+	name          string
+	email         string
+	emailVerified bool
+}
+
+// isOidcEmailVerified returns value of email_verified claim.
+// Some providers send it as a string.
+func isOidcEmailVerified(claims map[string]interface{}) bool {
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		return verified
+	case string:
+		return verified == "true"
+	default:
+		return false
+	}
 }
 
 func claimOidcToken(idToken *oidc.IDToken, provider util.OidcProvider) (res oidcClaimResult, err error) {
@@ -392,7 +632,9 @@ func claimOidcToken(idToken *oidc.IDToken, provider util.OidcProvider) (res oidc
 		return
 	}
 
+	res.emailVerified = isOidcEmailVerified(claims)
 	res.username = getUsernameFromEmail(res.email)
+	res.login, _ = claims[provider.UsernameClaim].(string)
 
 	res.name, ok = claims[provider.NameClaim].(string)
 	if !ok || res.name == "" {
@@ -440,7 +682,7 @@ func oidcRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := oidc.ClientContext(context.Background(), util.BuildHTTPClient(nil))
 	_oidc, oauth, err := getOidcProvider(pid, ctx)
 	if err != nil {
 		log.Error(err.Error())
@@ -455,11 +697,33 @@ func oidcRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	release, err := acquireAuthSlot()
+	if err != nil {
+		log.Warn(err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
 	verifier := _oidc.Verifier(&oidc.Config{ClientID: oauth.ClientID})
 
-	code := r.URL.Query().Get("code")
+	// code is in the body if response_mode is form_post
+	code := r.FormValue("code")
+
+	var exchangeOpts []oauth2.AuthCodeOption
+
+	if provider.UsePKCE {
+		var pkceVerifier *http.Cookie
+		pkceVerifier, err = r.Cookie("oauthpkce")
+		if err != nil {
+			log.Error(err.Error())
+			http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
+			return
+		}
+		exchangeOpts = append(exchangeOpts, oauth2.SetAuthURLParam("code_verifier", pkceVerifier.Value))
+	}
 
-	oauth2Token, err := oauth.Exchange(ctx, code)
+	oauth2Token, err := oauth.Exchange(ctx, code, exchangeOpts...)
 	if err != nil {
 		log.Error(err.Error())
 		http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
@@ -485,8 +749,14 @@ func oidcRedirect(w http.ResponseWriter, r *http.Request) {
 
 		if err == nil {
 			claims.email = userInfo.Email
+			claims.emailVerified = userInfo.EmailVerified
 			claims.username = getUsernameFromEmail(claims.email)
 
+			userInfoClaims := make(map[string]interface{})
+			if userInfo.Claims(&userInfoClaims) == nil {
+				claims.login, _ = userInfoClaims[provider.UsernameClaim].(string)
+			}
+
 			if userInfo.Profile != "" {
 				claims.name = userInfo.Profile
 			} else {
@@ -501,8 +771,39 @@ func oidcRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := helpers.Store(r).GetUserByLoginOrEmail("", claims.email) // ignore username because it creates a lot of problems
+	linking := util.Config.GetOidcAccountLinking()
+
+	var user db.User
+
+	switch linking {
+	case util.OidcAccountLinkingUsername:
+		if claims.login == "" {
+			err = fmt.Errorf("claim '%s' missing from id_token or not a string", provider.UsernameClaim)
+			break
+		}
+		claims.username = claims.login
+		user, err = helpers.Store(r).GetUserByLogin(claims.login)
+	default:
+		if claims.email == "" {
+			err = fmt.Errorf("claim '%s' of OIDC user is empty", provider.EmailClaim)
+			break
+		}
+		user, err = helpers.Store(r).GetUserByLoginOrEmail("", claims.email) // ignore username because it creates a lot of problems
+	}
+
+	if err != nil && err != db.ErrNotFound {
+		log.Error(err.Error())
+		http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
+		return
+	}
+
 	if err != nil {
+		if !util.Config.IsEmailDomainAllowed(claims.email) {
+			log.Error(fmt.Errorf("email domain of OIDC user '%s' is not allowed", claims.email))
+			http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
+			return
+		}
+
 		user = db.User{
 			Username: claims.username,
 			Name:     claims.name,
@@ -517,12 +818,28 @@ func oidcRedirect(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if !user.External {
+	// username may belong to unrelated account, e.g. local admin or user of
+	// LDAP or another provider, so it is linked only if emails match
+	if linking == util.OidcAccountLinkingUsername &&
+		(claims.email == "" || !strings.EqualFold(claims.email, user.Email)) {
+		log.Error(fmt.Errorf("OIDC user '%s' conflicts with existing user: email doesn't match", user.Username))
+		http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
+		return
+	}
+
+	if !user.External && linking == util.OidcAccountLinkingNone {
 		log.Error(fmt.Errorf("OIDC user '%s' conflicts with local user", user.Username))
 		http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
 		return
 	}
 
+	// local accounts can be taken over only by a user who proved the email
+	if !user.External && !claims.emailVerified {
+		log.Error(fmt.Errorf("OIDC user '%s' can't be linked to local user: email is not verified", user.Username))
+		http.Redirect(w, r, "/auth/login", http.StatusTemporaryRedirect)
+		return
+	}
+
 	createSession(w, r, user)
 
 	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
@@ -0,0 +1,470 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ansible-semaphore/semaphore/db"
+	"github.com/ansible-semaphore/semaphore/db/bolt"
+	"github.com/ansible-semaphore/semaphore/util"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/securecookie"
+)
+
+// newOidcTestServer starts identity provider which returns userInfo
+// for any authorization code.
+func newOidcTestServer(t *testing.T, userInfo map[string]interface{}) *httptest.Server {
+	router := http.NewServeMux()
+
+	router.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+		})
+	})
+
+	router.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(userInfo)
+	})
+
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// setupOidcTest configures provider `test` and returns store with created users
+func setupOidcTest(t *testing.T, linking string, userInfo map[string]interface{}, users ...db.User) db.Store {
+	srv := newOidcTestServer(t, userInfo)
+
+	oldConfig, oldCookie := util.Config, util.Cookie
+	t.Cleanup(func() {
+		util.Config, util.Cookie = oldConfig, oldCookie
+	})
+
+	var provider util.OidcProvider
+	if err := json.Unmarshal([]byte(`{
+		"client_id": "semaphore",
+		"client_secret": "secret",
+		"redirect_url": "http://localhost/api/auth/oidc/test/redirect",
+		"username_claim": "preferred_username",
+		"name_claim": "name",
+		"email_claim": "email",
+		"endpoint": {
+			"auth": "`+srv.URL+`/auth",
+			"token": "`+srv.URL+`/token",
+			"userinfo": "`+srv.URL+`/userinfo"
+		}
+	}`), &provider); err != nil {
+		t.Fatal(err)
+	}
+
+	util.Config = &util.ConfigType{
+		OidcAccountLinking: linking,
+		OidcProviders:      map[string]util.OidcProvider{"test": provider},
+	}
+	util.Cookie = securecookie.New(securecookie.GenerateRandomKey(32), nil)
+
+	store := bolt.CreateTestStore()
+
+	for _, user := range users {
+		email := user.Email
+		if email == "" {
+			user.Email = user.Username + "@example.com"
+		}
+
+		created, err := store.CreateUserWithoutPassword(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if email == "" {
+			created.Email = ""
+			if err = store.UpdateUser(db.UserWithPwd{User: created}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	return store
+}
+
+// serveOidcRedirect calls OIDC redirect handler of provider `test` with the cookies
+func serveOidcRedirect(store db.Store, state string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/test/redirect?code=code&state="+url.QueryEscape(state), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	req = mux.SetURLVars(req, map[string]string{"provider": "test"})
+	context.Set(req, "store", store)
+	defer context.Clear(req)
+
+	rr := httptest.NewRecorder()
+	oidcRedirect(rr, req)
+	return rr
+}
+
+// oidcRedirectUser calls OIDC redirect handler and returns ID of the logged in user
+// or 0 if login was rejected.
+func oidcRedirectUser(t *testing.T, store db.Store) int {
+	rr := serveOidcRedirect(store, "state", &http.Cookie{Name: "oauthstate", Value: "state"})
+
+	if rr.Header().Get("Location") != "/" {
+		return 0
+	}
+
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name != "semaphore" {
+			continue
+		}
+
+		value := make(map[string]interface{})
+		if err := util.Cookie.Decode("semaphore", cookie.Value, &value); err != nil {
+			t.Fatal(err)
+		}
+		return value["user"].(int)
+	}
+
+	t.Fatal("Session cookie is not set")
+	return 0
+}
+
+func TestOidcRedirectLinkingNone(t *testing.T) {
+	store := setupOidcTest(t, util.OidcAccountLinkingNone, map[string]interface{}{
+		"sub":            "1",
+		"email":          "admin@example.com",
+		"email_verified": true,
+	}, db.User{Username: "admin", Name: "Admin", Email: "admin@example.com", Admin: true})
+
+	if oidcRedirectUser(t, store) != 0 {
+		t.Error("OIDC user must not be linked to local user")
+	}
+
+	store = setupOidcTest(t, util.OidcAccountLinkingNone, map[string]interface{}{
+		"sub":   "2",
+		"email": "sso@example.com",
+	}, db.User{Username: "sso", Name: "SSO", Email: "sso@example.com", External: true})
+
+	sso, err := store.GetUserByLogin("sso")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if oidcRedirectUser(t, store) != sso.ID {
+		t.Error("OIDC user must be logged in as existing external user")
+	}
+}
+
+func TestOidcRedirectLinkingEmail(t *testing.T) {
+	store := setupOidcTest(t, util.OidcAccountLinkingEmail, map[string]interface{}{
+		"sub":            "1",
+		"email":          "admin@example.com",
+		"email_verified": true,
+	}, db.User{Username: "admin", Name: "Admin", Email: "admin@example.com", Admin: true})
+
+	admin, err := store.GetUserByLogin("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if oidcRedirectUser(t, store) != admin.ID {
+		t.Error("OIDC user with verified email must be linked to local user")
+	}
+
+	store = setupOidcTest(t, util.OidcAccountLinkingEmail, map[string]interface{}{
+		"sub":   "1",
+		"email": "admin@example.com",
+	}, db.User{Username: "admin", Name: "Admin", Email: "admin@example.com", Admin: true})
+
+	if oidcRedirectUser(t, store) != 0 {
+		t.Error("OIDC user with unverified email must not be linked to local user")
+	}
+}
+
+func TestOidcRedirectLinkingUsername(t *testing.T) {
+	store := setupOidcTest(t, util.OidcAccountLinkingUsername, map[string]interface{}{
+		"sub":                "1",
+		"email":              "john@example.com",
+		"email_verified":     true,
+		"preferred_username": "john",
+	}, db.User{Username: "noemail", Name: "No Email", Admin: true})
+
+	userID := oidcRedirectUser(t, store)
+
+	john, err := store.GetUserByLogin("john")
+	if err != nil {
+		t.Fatal("OIDC user was not created: " + err.Error())
+	}
+
+	if userID != john.ID || !john.External {
+		t.Error("OIDC user must not be linked to user with empty email")
+	}
+
+	store = setupOidcTest(t, util.OidcAccountLinkingUsername, map[string]interface{}{
+		"sub":                "1",
+		"email":              "other@example.com",
+		"preferred_username": "admin",
+	}, db.User{Username: "admin", Name: "Admin", Email: "admin@example.com", Admin: true})
+
+	if oidcRedirectUser(t, store) != 0 {
+		t.Error("OIDC user with unverified email must not be linked to local user")
+	}
+}
+
+func TestOidcRedirectLinkingUsernameTakeover(t *testing.T) {
+	store := setupOidcTest(t, util.OidcAccountLinkingUsername, map[string]interface{}{
+		"sub":                "1",
+		"email":              "attacker@example.com",
+		"email_verified":     true,
+		"preferred_username": "admin",
+	}, db.User{Username: "admin", Name: "Admin", Email: "admin@example.com", Admin: true})
+
+	if oidcRedirectUser(t, store) != 0 {
+		t.Error("OIDC user with other email must not be linked to local user")
+	}
+
+	store = setupOidcTest(t, util.OidcAccountLinkingUsername, map[string]interface{}{
+		"sub":                "1",
+		"email":              "attacker@example.com",
+		"email_verified":     true,
+		"preferred_username": "ldapuser",
+	}, db.User{Username: "ldapuser", Name: "LDAP User", Email: "ldapuser@example.com", External: true})
+
+	if oidcRedirectUser(t, store) != 0 {
+		t.Error("OIDC user with other email must not be linked to external user")
+	}
+
+	store = setupOidcTest(t, util.OidcAccountLinkingUsername, map[string]interface{}{
+		"sub":                "1",
+		"email":              "Admin@example.com",
+		"email_verified":     true,
+		"preferred_username": "admin",
+	}, db.User{Username: "admin", Name: "Admin", Email: "admin@example.com", Admin: true})
+
+	admin, err := store.GetUserByLogin("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if oidcRedirectUser(t, store) != admin.ID {
+		t.Error("OIDC user with verified matching email must be linked to local user")
+	}
+}
+
+func TestOidcPKCE(t *testing.T) {
+	userInfo := map[string]interface{}{"sub": "1", "email": "sso@example.com"}
+	store := setupOidcTest(t, util.OidcAccountLinkingNone, userInfo,
+		db.User{Username: "sso", Name: "SSO", Email: "sso@example.com", External: true})
+
+	var challenge string
+
+	router := http.NewServeMux()
+	router.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if challenge == "" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "access-token", "token_type": "Bearer"})
+	})
+	router.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(userInfo)
+	})
+	idp := httptest.NewServer(router)
+	defer idp.Close()
+
+	provider := util.Config.OidcProviders["test"]
+	provider.UsePKCE = true
+	provider.Endpoint.TokenURL = idp.URL + "/token"
+	provider.Endpoint.UserInfoURL = idp.URL + "/userinfo"
+	util.Config.OidcProviders["test"] = provider
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/test/login", nil)
+	req = mux.SetURLVars(req, map[string]string{"provider": "test"})
+	rr := httptest.NewRecorder()
+	oidcLogin(rr, req)
+
+	authURL, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge = authURL.Query().Get("code_challenge")
+	if challenge == "" || authURL.Query().Get("code_challenge_method") != "S256" {
+		t.Fatal("PKCE challenge is not sent: " + authURL.String())
+	}
+
+	cookies := rr.Result().Cookies()
+	state := authURL.Query().Get("state")
+
+	if serveOidcRedirect(store, state, cookies...).Header().Get("Location") != "/" {
+		t.Error("Code was not exchanged with PKCE verifier")
+	}
+
+	var stateCookies []*http.Cookie
+	for _, cookie := range cookies {
+		if cookie.Name == "oauthstate" {
+			stateCookies = append(stateCookies, cookie)
+		}
+	}
+
+	if serveOidcRedirect(store, state, stateCookies...).Header().Get("Location") != "/auth/login" {
+		t.Error("Login without PKCE verifier must be rejected")
+	}
+}
+
+func TestAcquireAuthSlot(t *testing.T) {
+	oldConfig, oldTimeout := util.Config, authSlotTimeout
+	t.Cleanup(func() {
+		util.Config, authSlotTimeout = oldConfig, oldTimeout
+	})
+
+	authSlotTimeout = 10 * time.Millisecond
+	util.Config = &util.ConfigType{MaxConcurrentAuth: 1}
+
+	release, err := acquireAuthSlot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = acquireAuthSlot(); err != errAuthSlotTimeout {
+		t.Fatal("Authentication over the limit must time out")
+	}
+
+	release()
+
+	if release, err = acquireAuthSlot(); err != nil {
+		t.Fatal("Released slot must be available: " + err.Error())
+	}
+
+	// reloaded config
+	util.Config = &util.ConfigType{MaxConcurrentAuth: 2}
+
+	releaseNew, err := acquireAuthSlot()
+	if err != nil {
+		t.Fatal("Changed limit must be used: " + err.Error())
+	}
+
+	release()
+	releaseNew()
+}
+
+func TestOidcRedirectAuthSlotTimeout(t *testing.T) {
+	store := setupOidcTest(t, util.OidcAccountLinkingNone, map[string]interface{}{
+		"sub":   "1",
+		"email": "sso@example.com",
+	})
+
+	oldTimeout := authSlotTimeout
+	t.Cleanup(func() { authSlotTimeout = oldTimeout })
+
+	authSlotTimeout = 10 * time.Millisecond
+	util.Config.MaxConcurrentAuth = 1
+
+	release, err := acquireAuthSlot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	rr := serveOidcRedirect(store, "state", &http.Cookie{Name: "oauthstate", Value: "state"})
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+// newOidcDiscoveryServer starts identity provider which serves discovery document.
+// Discovery waits until block is closed if it is not nil.
+func newOidcDiscoveryServer(t *testing.T, block chan struct{}) *httptest.Server {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if block != nil {
+			<-block
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/auth",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/keys",
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestGetCachedOidcProviderReload(t *testing.T) {
+	oldConfig := util.Config
+	t.Cleanup(func() { util.Config = oldConfig })
+
+	util.Config = &util.ConfigType{OidcJWKSCacheTTLSeconds: 3600}
+	ctx := httptest.NewRequest("GET", "/", nil).Context()
+
+	first := newOidcDiscoveryServer(t, nil)
+	second := newOidcDiscoveryServer(t, nil)
+
+	provider, err := getCachedOidcProvider("reload", util.OidcProvider{AutoDiscovery: first.URL}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.Endpoint().AuthURL != first.URL+"/auth" {
+		t.Fatal("Invalid provider: " + provider.Endpoint().AuthURL)
+	}
+
+	// provider_url is changed by config reload
+	provider, err = getCachedOidcProvider("reload", util.OidcProvider{AutoDiscovery: second.URL}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.Endpoint().AuthURL != second.URL+"/auth" {
+		t.Error("Stale provider was served after reload: " + provider.Endpoint().AuthURL)
+	}
+}
+
+func TestGetCachedOidcProviderSlowDiscovery(t *testing.T) {
+	oldConfig := util.Config
+	t.Cleanup(func() { util.Config = oldConfig })
+
+	util.Config = &util.ConfigType{OidcJWKSCacheTTLSeconds: 3600}
+	ctx := httptest.NewRequest("GET", "/", nil).Context()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	slow := newOidcDiscoveryServer(t, block)
+	fast := newOidcDiscoveryServer(t, nil)
+
+	go func() {
+		_, _ = getCachedOidcProvider("slow", util.OidcProvider{AutoDiscovery: slow.URL}, ctx)
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := getCachedOidcProvider("fast", util.OidcProvider{AutoDiscovery: fast.URL}, ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Slow provider discovery must not block other providers")
+	}
+}
@@ -87,7 +87,7 @@ func Route() *mux.Router {
 	publicAPIRouter.HandleFunc("/auth/login", login).Methods("GET", "POST")
 	publicAPIRouter.HandleFunc("/auth/logout", logout).Methods("POST")
 	publicAPIRouter.HandleFunc("/auth/oidc/{provider}/login", oidcLogin).Methods("GET")
-	publicAPIRouter.HandleFunc("/auth/oidc/{provider}/redirect", oidcRedirect).Methods("GET")
+	publicAPIRouter.HandleFunc("/auth/oidc/{provider}/redirect", oidcRedirect).Methods("GET", "POST")
 
 	routersAPI := r.PathPrefix(webPath + "api").Subrouter()
 	routersAPI.Use(StoreMiddleware, JSONMiddleware, runners.RunnerMiddleware)
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/ansible-semaphore/semaphore/util"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(bugreportCmd)
+}
+
+var bugreportCmd = &cobra.Command{
+	Use:   "bugreport",
+	Short: "Print configuration and environment info for bug reports (secrets are masked)",
+	Run: func(cmd *cobra.Command, args []string) {
+		util.ConfigInit(configPath)
+		fmt.Print(util.Config.SanitizedReport())
+	},
+}
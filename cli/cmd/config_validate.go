@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/ansible-semaphore/semaphore/util"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check configuration file set by --config without starting the server",
+	Run: func(cmd *cobra.Command, args []string) {
+		if configPath == "" {
+			fmt.Fprintln(os.Stderr, "Use --config parameter to point to the configuration file")
+			os.Exit(1)
+		}
+
+		errs := util.ValidateConfigFile(configPath)
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+
+		if len(errs) > 0 {
+			os.Exit(1)
+		}
+
+		fmt.Println("Configuration is valid")
+	},
+}
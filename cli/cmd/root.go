@@ -15,7 +15,8 @@ import (
 	"github.com/spf13/cobra"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 )
 
 var configPath string
@@ -49,20 +50,24 @@ func runService() {
 
 	util.Config.PrintDbInfo()
 
-	port := util.Config.Port
+	bootstrapAdmin(store)
 
-	if !strings.HasPrefix(port, ":") {
-		port = ":" + port
+	bindAddress, err := util.Config.BindAddress()
+	if err != nil {
+		log.Panic(err)
 	}
 
 	fmt.Printf("Tmp Path (projects home) %v\n", util.Config.TmpPath)
 	fmt.Printf("Semaphore %v\n", util.Version)
-	fmt.Printf("Interface %v\n", util.Config.Interface)
-	fmt.Printf("Port %v\n", util.Config.Port)
+	fmt.Printf("Listening on %v\n", util.Config.EffectiveListenURL())
+	if externalURL := util.Config.ExternalURL(); externalURL != "" {
+		fmt.Printf("External URL %v\n", externalURL)
+	}
 
 	go sockets.StartWS()
 	go schedulePool.Run()
 	go taskPool.Run()
+	go reloadConfigOnSignal()
 
 	route := api.Route()
 
@@ -88,13 +93,68 @@ func runService() {
 		store.Close("root")
 	}
 
-	err := http.ListenAndServe(util.Config.Interface+port, cropTrailingSlashMiddleware(router))
+	err = http.ListenAndServe(bindAddress, cropTrailingSlashMiddleware(router))
 
 	if err != nil {
 		log.Panic(err)
 	}
 }
 
+// reloadConfigOnSignal reloads config when the process receives SIGHUP
+func reloadConfigOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		if err := util.ReloadConfig(""); err != nil {
+			log.Errorf("Config is not reloaded: %v", err)
+			continue
+		}
+		log.Info("Config reloaded")
+	}
+}
+
+// bootstrapAdmin creates admin from SEMAPHORE_ADMIN_* environment variables
+// if there are no users yet.
+func bootstrapAdmin(store db.Store) {
+	admin, err := util.GetAdminBootstrap()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if admin == nil {
+		return
+	}
+
+	users, err := store.GetUsers(db.RetrieveQueryParams{Count: 1})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	if len(users) > 0 {
+		return
+	}
+
+	_, err = store.CreateUser(db.UserWithPwd{
+		Pwd: admin.Password,
+		User: db.User{
+			Name:     admin.Name,
+			Username: admin.Login,
+			Email:    admin.Email,
+			Admin:    true,
+		},
+	})
+
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	log.Warnf("Admin %s created from SEMAPHORE_ADMIN_PASSWORD. Change the password and remove the variable!", admin.Login)
+}
+
 func createStore(token string) db.Store {
 	util.ConfigInit(configPath)
 
@@ -27,7 +27,9 @@ var setupCmd = &cobra.Command{
 // nolint: gocyclo
 func doSetup() int {
 	var config *util.ConfigType
-	config = &util.ConfigType{}
+	config = &util.ConfigType{
+		ConfigVersion: util.CurrentConfigVersion,
+	}
 	config.GenerateSecrets()
 	setup.InteractiveSetup(config)
 
@@ -19,13 +19,23 @@ import (
 
 // doUpgrade checks for an update, and if available downloads the binary and installs it
 func doUpgrade() error {
-	updateAvailable, err := util.CheckUpdate()
+	update := util.CheckUpdate()
 
-	if err != nil || updateAvailable == nil {
-		return err
+	if update.Offline {
+		fmt.Println("update check is unavailable in offline mode")
+		return nil
+	}
+
+	if !update.Reachable {
+		return update.Error
+	}
+
+	if !update.Available {
+		fmt.Println("semaphore is up to date")
+		return nil
 	}
 
-	asset := findAsset(updateAvailable)
+	asset := findAsset(update.Release)
 	if asset == nil {
 		return errors.New("cannot find binary for your system")
 	}
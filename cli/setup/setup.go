@@ -146,8 +146,25 @@ func SaveConfig(config *util.ConfigType) (configPath string) {
 		log.Panic("Could not create config directory: " + err.Error())
 	}
 
+	var separateSecrets bool
+	askConfirmation("Store secret keys in a separate file?", false, &separateSecrets)
+
+	// keep secret keys in the running config
+	saved := *config
+
+	if separateSecrets {
+		secretsPath := filepath.Join(configDirectory, "secrets.json")
+		askValue("Secrets file path", secretsPath, &secretsPath)
+
+		if err = saved.WriteSecretsFile(secretsPath); err != nil {
+			log.Panic("Could not write secrets file: " + err.Error())
+		}
+
+		fmt.Printf("Secret keys written to %v..\n", secretsPath)
+	}
+
 	// Marshal config to json
-	bytes, err := config.ToJSON()
+	bytes, err := saved.ToJSON()
 	if err != nil {
 		panic(err)
 	}
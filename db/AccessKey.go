@@ -278,8 +278,27 @@ func (key *AccessKey) unmarshalAppropriateField(secret []byte) (err error) {
 	return
 }
 
+// DeserializeSecret decrypts the secret by the current encryption key
+// or, during key rotation, by one of the previous keys.
 func (key *AccessKey) DeserializeSecret() error {
-	return key.DeserializeSecret2(util.Config.AccessKeyEncryption)
+	encryptionKeys := util.Config.GetAccessKeyEncryptionKeys()
+
+	if len(encryptionKeys) == 0 {
+		return key.DeserializeSecret2("")
+	}
+
+	var firstErr error
+	for _, encryption := range encryptionKeys {
+		err := key.DeserializeSecret2(base64.StdEncoding.EncodeToString(encryption))
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
 }
 
 func (key *AccessKey) DeserializeSecret2(encryptionString string) error {
@@ -89,3 +89,41 @@ func TestSetGetSecretWithEncryption(t *testing.T) {
 		t.Error("invalid secret")
 	}
 }
+
+func TestGetSecretWithRotatedEncryption(t *testing.T) {
+	oldEncryption := "hHYgPrhQTZYm7UFTvcdNfKJMB3wtAXtJENUButH+DmM="
+
+	accessKey := AccessKey{
+		Type: AccessKeySSH,
+		SshKey: SshKey{
+			PrivateKey: "qerphqeruqoweurqwerqqeuiqwpavqr",
+		},
+	}
+
+	util.Config = &util.ConfigType{
+		AccessKeyEncryption: oldEncryption,
+	}
+
+	if err := accessKey.SerializeSecret(); err != nil {
+		t.Fatal(err)
+	}
+
+	util.Config = &util.ConfigType{
+		AccessKeyEncryption: "1/wRYXQltDGwbzNZRP9ZfJb2IoWcn1hYrxA0vOdvVos=",
+	}
+
+	accessKey.SshKey = SshKey{}
+	if accessKey.DeserializeSecret() == nil {
+		t.Error("Secret must not be decrypted by another key")
+	}
+
+	util.Config.AccessKeyEncryptionOld = []string{oldEncryption}
+
+	if err := accessKey.DeserializeSecret(); err != nil {
+		t.Fatal(err)
+	}
+
+	if accessKey.SshKey.PrivateKey != "qerphqeruqoweurqwerqqeuiqwpavqr" {
+		t.Error("invalid secret")
+	}
+}
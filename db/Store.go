@@ -141,6 +141,7 @@ type Store interface {
 	SetUserPassword(userID int, password string) error
 	GetUser(userID int) (User, error)
 	GetUserByLoginOrEmail(login string, email string) (User, error)
+	GetUserByLogin(login string) (User, error)
 
 	GetProject(projectID int) (Project, error)
 	GetAllProjects() ([]Project, error)
@@ -198,3 +198,21 @@ func (d *BoltDb) GetUserByLoginOrEmail(login string, email string) (existingUser
 	err = db.ErrNotFound
 	return
 }
+
+func (d *BoltDb) GetUserByLogin(login string) (existingUser db.User, err error) {
+	var users []db.User
+	err = d.getObjects(0, db.UserProps, db.RetrieveQueryParams{}, nil, &users)
+	if err != nil {
+		return
+	}
+
+	for _, user := range users {
+		if user.Username == login {
+			existingUser = user
+			return
+		}
+	}
+
+	err = db.ErrNotFound
+	return
+}
@@ -15,12 +15,35 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type SqlDb struct {
 	sql *gorp.DbMap
 }
 
+// queryLogger receives queries traced by gorp. Query arguments are not
+// logged because they can contain secrets.
+type queryLogger struct {
+	slowThreshold time.Duration
+}
+
+func (l queryLogger) Printf(format string, v ...interface{}) {
+	// gorp passes prefix, query, arguments and duration
+	if len(v) != 4 {
+		return
+	}
+
+	duration, ok := v[3].(time.Duration)
+	if !ok || duration < l.slowThreshold {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"duration": duration,
+	}).Info(fmt.Sprintf("%v%v", v[0], v[1]))
+}
+
 var initialSQL = `
 create table ` + "`migrations`" + ` (
 	` + "`version`" + ` varchar(255) not null primary key,
@@ -150,8 +173,24 @@ func connect() (*sql.DB, error) {
 		return nil, err
 	}
 
+	pool, err := cfg.GetPoolSettings()
+	if err != nil {
+		return nil, err
+	}
+
 	dialect := cfg.Dialect
-	return sql.Open(dialect, connectionString)
+	conn, err := sql.Open(dialect, connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns != 0 {
+		conn.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	conn.SetConnMaxLifetime(pool.ConnMaxLifetime)
+
+	return conn, nil
 }
 
 func createDb() error {
@@ -307,6 +346,10 @@ func (d *SqlDb) Connect(token string) {
 
 	d.sql = &gorp.DbMap{Db: sqlDb, Dialect: dialect}
 
+	if logQueries, slowThreshold := util.Config.GetDbQueryLogging(); logQueries {
+		d.sql.TraceOn("[sql]", queryLogger{slowThreshold: slowThreshold})
+	}
+
 	d.sql.AddTableWithName(db.APIToken{}, "user__token").SetKeys(false, "id")
 	d.sql.AddTableWithName(db.AccessKey{}, "access_key").SetKeys(true, "id")
 	d.sql.AddTableWithName(db.Environment{}, "project__environment").SetKeys(true, "id")
@@ -1,8 +1,13 @@
 package sql
 
 import (
+	"bytes"
+	log "github.com/Sirupsen/logrus"
 	"github.com/go-gorp/gorp/v3"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidatePort(t *testing.T) {
@@ -11,4 +16,25 @@ func TestValidatePort(t *testing.T) {
 	if q != "select * from \"test\" where id = $1, email = $2" {
 		t.Error("invalid postgres query")
 	}
-}
\ No newline at end of file
+}
+
+func TestQueryLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	logger := queryLogger{slowThreshold: 100 * time.Millisecond}
+
+	logger.Printf("%s%s [%s] (%v)", "[sql] ", "select 1", "secret", 10*time.Millisecond)
+	if buf.Len() > 0 {
+		t.Error("fast query must not be logged")
+	}
+
+	logger.Printf("%s%s [%s] (%v)", "[sql] ", "select 2", "secret", 200*time.Millisecond)
+	if !strings.Contains(buf.String(), "select 2") {
+		t.Error("slow query must be logged")
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Error("query arguments must not be logged")
+	}
+}
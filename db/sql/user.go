@@ -216,3 +216,16 @@ func (d *SqlDb) GetUserByLoginOrEmail(login string, email string) (existingUser
 
 	return
 }
+
+func (d *SqlDb) GetUserByLogin(login string) (existingUser db.User, err error) {
+	err = d.selectOne(
+		&existingUser,
+		d.PrepareQuery("select * from `user` where username=?"),
+		login)
+
+	if err == sql.ErrNoRows {
+		err = db.ErrNotFound
+	}
+
+	return
+}
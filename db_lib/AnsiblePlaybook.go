@@ -7,6 +7,7 @@ import (
 	"github.com/ansible-semaphore/semaphore/util"
 	"os"
 	"os/exec"
+	"path"
 	"strings"
 )
 
@@ -29,6 +30,16 @@ func (p AnsiblePlaybook) makeCmd(command string, args []string, environmentVars
 		cmd.Env = append(cmd.Env, *environmentVars...)
 	}
 
+	// set after environmentVars, so denied modules can't be allowed by environment of template
+	if filters := util.Config.GetAnsiblePluginFilters(); filters != "" {
+		filtersPath := path.Join(util.Config.TmpPath, "ansible_plugin_filters.yml")
+		if err := os.WriteFile(filtersPath, []byte(filters), 0644); err != nil {
+			p.Logger.Log("Can't write ansible plugin filters: " + err.Error())
+		} else {
+			cmd.Env = append(cmd.Env, "ANSIBLE_PLUGIN_FILTERS_CFG="+filtersPath)
+		}
+	}
+
 	sensitiveEnvs := []string{
 		"SEMAPHORE_ACCESS_KEY_ENCRYPTION",
 		"SEMAPHORE_ADMIN_PASSWORD",
@@ -53,8 +64,48 @@ func (p AnsiblePlaybook) runCmd(command string, args []string) error {
 	return cmd.Run()
 }
 
+// executionEnvironmentArgs converts ansible-playbook arguments, with playbook as the last one,
+// to ansible-navigator arguments which run the playbook in the container image.
+// TmpPath is mounted because inventories and keys of the task are installed there.
+func executionEnvironmentArgs(engine string, image string, args []string, environmentVars []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	playbook := args[len(args)-1]
+
+	navigatorArgs := []string{
+		"run", playbook,
+		"--mode", "stdout",
+		"--execution-environment", "true",
+		"--container-engine", engine,
+		"--execution-environment-image", image,
+		"--execution-environment-volume-mounts", util.Config.TmpPath + ":" + util.Config.TmpPath,
+	}
+
+	for _, env := range environmentVars {
+		if name := strings.SplitN(env, "=", 2)[0]; name != "" {
+			navigatorArgs = append(navigatorArgs, "--pass-environment-variable", name)
+		}
+	}
+
+	return append(navigatorArgs, args[:len(args)-1]...)
+}
+
 func (p AnsiblePlaybook) RunPlaybook(args []string, environmentVars *[]string, cb func(*os.Process)) error {
-	cmd := p.makeCmd("ansible-playbook", args, environmentVars)
+	command := "ansible-playbook"
+
+	if util.Config.UseAnsibleExecutionEnvironment() {
+		var envs []string
+		if environmentVars != nil {
+			envs = *environmentVars
+		}
+		engine, image := util.Config.GetAnsibleExecutionEnvironment()
+		command = "ansible-navigator"
+		args = executionEnvironmentArgs(engine, image, args, envs)
+	}
+
+	cmd := p.makeCmd(command, args, environmentVars)
 	p.Logger.LogCmd(cmd)
 	cmd.Stdin = strings.NewReader("")
 	err := cmd.Start()
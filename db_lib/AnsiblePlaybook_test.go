@@ -0,0 +1,31 @@
+package db_lib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ansible-semaphore/semaphore/util"
+)
+
+func TestExecutionEnvironmentArgs(t *testing.T) {
+	util.Config = &util.ConfigType{TmpPath: "/tmp/semaphore"}
+
+	args := executionEnvironmentArgs("podman", "quay.io/ansible/creator-ee:latest",
+		[]string{"-i", "/tmp/semaphore/inventory_1", "--check", "site.yml"},
+		[]string{"SSH_AUTH_SOCK=/tmp/semaphore/agent.sock"})
+
+	expected := []string{
+		"run", "site.yml",
+		"--mode", "stdout",
+		"--execution-environment", "true",
+		"--container-engine", "podman",
+		"--execution-environment-image", "quay.io/ansible/creator-ee:latest",
+		"--execution-environment-volume-mounts", "/tmp/semaphore:/tmp/semaphore",
+		"--pass-environment-variable", "SSH_AUTH_SOCK",
+		"-i", "/tmp/semaphore/inventory_1", "--check",
+	}
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Invalid ansible-navigator arguments: %v", args)
+	}
+}
@@ -3,7 +3,16 @@ package db_lib
 import "github.com/ansible-semaphore/semaphore/util"
 
 func CreateDefaultGitClient() GitClient {
-	switch util.Config.GitClientId {
+	return createGitClient(util.GitClientId(util.Config.GitClientId))
+}
+
+// CreateProjectGitClient creates Git client configured for the project.
+func CreateProjectGitClient(projectID int) GitClient {
+	return createGitClient(util.Config.GetGitClientForProject(projectID))
+}
+
+func createGitClient(clientID util.GitClientId) GitClient {
+	switch clientID {
 	case util.GoGitClientId:
 		return CreateGoGitClient()
 	case util.CmdGitClientId:
@@ -266,7 +266,7 @@ func (p *JobPool) Run() {
 
 func (p *JobPool) sendProgress() {
 
-	client := &http.Client{}
+	client := util.BuildHTTPClient(nil)
 
 	url := util.Config.Runner.ApiURL + "/runners/" + strconv.Itoa(p.config.RunnerID)
 
@@ -344,7 +344,7 @@ func (p *JobPool) tryRegisterRunner() bool {
 		panic("registration token cannot be empty")
 	}
 
-	client := &http.Client{}
+	client := util.BuildHTTPClient(nil)
 
 	url := util.Config.Runner.ApiURL + "/runners"
 
@@ -397,7 +397,7 @@ func (p *JobPool) tryRegisterRunner() bool {
 // checkNewJobs tries to find runner to queued jobs
 func (p *JobPool) checkNewJobs() {
 
-	client := &http.Client{}
+	client := util.BuildHTTPClient(nil)
 
 	url := util.Config.Runner.ApiURL + "/runners/" + strconv.Itoa(p.config.RunnerID)
 
@@ -31,7 +31,7 @@ func (r ScheduleRunner) tryUpdateScheduleCommitHash(schedule db.Schedule) (updat
 		Logger:     nil,
 		TemplateID: schedule.TemplateID,
 		Repository: repo,
-		Client:     db_lib.CreateDefaultGitClient(),
+		Client:     db_lib.CreateProjectGitClient(repo.ProjectID),
 	}.GetLastRemoteCommitHash()
 
 	if err != nil {
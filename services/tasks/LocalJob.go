@@ -306,7 +306,7 @@ func (t *LocalJob) updateRepository() error {
 		Logger:     t.Logger,
 		TemplateID: t.Template.ID,
 		Repository: t.Repository,
-		Client:     db_lib.CreateDefaultGitClient(),
+		Client:     db_lib.CreateProjectGitClient(t.Repository.ProjectID),
 	}
 
 	err := repo.ValidateRepo()
@@ -342,7 +342,7 @@ func (t *LocalJob) checkoutRepository() error {
 		Logger:     t.Logger,
 		TemplateID: t.Template.ID,
 		Repository: t.Repository,
-		Client:     db_lib.CreateDefaultGitClient(),
+		Client:     db_lib.CreateProjectGitClient(t.Repository.ProjectID),
 	}
 
 	err := repo.ValidateRepo()
@@ -388,7 +388,7 @@ func (t *LocalJob) getRepoPath() string {
 		Logger:     t.Logger,
 		TemplateID: t.Template.ID,
 		Repository: t.Repository,
-		Client:     db_lib.CreateDefaultGitClient(),
+		Client:     db_lib.CreateProjectGitClient(t.Repository.ProjectID),
 	}
 
 	return repo.GetFullPath()
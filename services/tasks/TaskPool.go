@@ -1,12 +1,14 @@
 package tasks
 
 import (
+	"errors"
 	"github.com/ansible-semaphore/semaphore/db"
 	"github.com/ansible-semaphore/semaphore/db_lib"
 	"github.com/ansible-semaphore/semaphore/lib"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -42,6 +44,11 @@ type TaskPool struct {
 	store db.Store
 
 	resourceLocker chan *resourceLock
+
+	// activeTasks is number of tasks added by AddTask which are not finished yet:
+	// registered, queued or running. It is updated atomically because AddTask
+	// is called concurrently and queue is owned by Run.
+	activeTasks int32
 }
 
 func (p *TaskPool) GetNumberOfRunningTasksOfRunner(runnerID int) (res int) {
@@ -118,6 +125,7 @@ func (p *TaskPool) Run() {
 			}
 
 			delete(p.runningTasks, t.Task.ID)
+			p.releaseTask()
 		}
 	}(p.resourceLocker)
 
@@ -156,6 +164,7 @@ func (p *TaskPool) Run() {
 			if t.Task.Status == lib.TaskFailStatus {
 				//delete failed TaskRunner from queue
 				p.queue = p.queue[1:]
+				p.releaseTask()
 				log.Info("Task " + strconv.Itoa(t.Task.ID) + " removed from queue")
 				break
 			}
@@ -299,7 +308,55 @@ func getNextBuildVersion(startVersion string, currentVersion string) string {
 	return prefix + strconv.Itoa(newVer) + suffix
 }
 
+// ErrTaskPoolFull is returned by AddTask if MaxParallelTasks is reached
+// and TaskOverflowPolicy is "reject".
+var ErrTaskPoolFull = errors.New("maximum number of parallel tasks is reached")
+
+// isFull returns true if new tasks should be rejected according to TaskOverflowPolicy
+func (p *TaskPool) isFull() bool {
+	return p.isFullWith(atomic.LoadInt32(&p.activeTasks))
+}
+
+func (p *TaskPool) isFullWith(activeTasks int32) bool {
+	if util.Config.GetTaskOverflowPolicy() != util.TaskOverflowReject || util.Config.MaxParallelTasks <= 0 {
+		return false
+	}
+
+	return int(activeTasks) >= util.Config.MaxParallelTasks
+}
+
+// reserveTask counts new task as active. It returns false if the pool is full,
+// so checking and counting can't be interleaved by concurrent AddTask calls.
+func (p *TaskPool) reserveTask() bool {
+	for {
+		active := atomic.LoadInt32(&p.activeTasks)
+		if p.isFullWith(active) {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&p.activeTasks, active, active+1) {
+			return true
+		}
+	}
+}
+
+// releaseTask counts finished or dropped task as inactive
+func (p *TaskPool) releaseTask() {
+	atomic.AddInt32(&p.activeTasks, -1)
+}
+
 func (p *TaskPool) AddTask(taskObj db.Task, userID *int, projectID int) (newTask db.Task, err error) {
+	if !p.reserveTask() {
+		err = ErrTaskPoolFull
+		return
+	}
+
+	registered := false
+	defer func() {
+		if !registered {
+			p.releaseTask()
+		}
+	}()
+
 	taskObj.Created = time.Now()
 	taskObj.Status = lib.TaskWaitingStatus
 	taskObj.UserID = userID
@@ -386,6 +443,7 @@ func (p *TaskPool) AddTask(taskObj db.Task, userID *int, projectID int) (newTask
 	taskRunner.job = job
 
 	p.register <- &taskRunner
+	registered = true
 
 	objType := db.EventTask
 	desc := "Task ID " + strconv.Itoa(newTask.ID) + " queued for running"
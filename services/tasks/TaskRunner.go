@@ -89,14 +89,33 @@ func (t *TaskRunner) SetStatus(status lib.TaskStatus) {
 
 	t.saveStatus()
 
-	if status == lib.TaskFailStatus {
-		t.sendMailAlert()
+	if status == lib.TaskSuccessStatus || status == lib.TaskFailStatus {
+		// alerts are sent in background by a copy of the runner, so retries
+		// of unavailable channels don't hold the task
+		alerts := *t
+		go alerts.sendAlerts()
 	}
+}
+
+// sendAlerts delivers alerts about finished task to all enabled channels
+func (t *TaskRunner) sendAlerts() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("Can't send alerts of task %d: %v", t.Task.ID, r)
+		}
+	}()
+
+	db.StoreSession(t.pool.store, "alerts of task "+strconv.Itoa(t.Task.ID), func() {
+		if t.Task.Status == lib.TaskFailStatus {
+			t.sendMailAlert()
+		}
 
-	if status == lib.TaskSuccessStatus || status == lib.TaskFailStatus {
 		t.sendTelegramAlert()
 		t.sendSlackAlert()
-	}
+		t.sendDiscordAlert()
+		t.sendMsTeamsAlert()
+		t.sendWebhookAlert()
+	})
 }
 
 func (t *TaskRunner) saveStatus() {
@@ -7,6 +7,8 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -424,3 +426,63 @@ func TestCheckTmpDir(t *testing.T) {
 		t.Log(err)
 	}
 }
+
+func TestAddTaskOverflowReject(t *testing.T) {
+	util.Config = &util.ConfigType{
+		TmpPath:            "/tmp",
+		MaxParallelTasks:   1,
+		TaskOverflowPolicy: util.TaskOverflowReject,
+	}
+
+	pool := CreateTaskPool(CreateBoltDB())
+	pool.activeTasks = 1
+
+	_, err := pool.AddTask(db.Task{}, nil, 1)
+	if err != ErrTaskPoolFull {
+		t.Fatal("Task must be rejected when pool is full")
+	}
+
+	util.Config.TaskOverflowPolicy = util.TaskOverflowQueue
+	if pool.isFull() {
+		t.Fatal("Tasks must be queued by default")
+	}
+}
+
+func TestAddTaskOverflowRejectConcurrent(t *testing.T) {
+	util.Config = &util.ConfigType{
+		TmpPath:            "/tmp",
+		MaxParallelTasks:   3,
+		TaskOverflowPolicy: util.TaskOverflowReject,
+	}
+
+	pool := CreateTaskPool(bolt.CreateTestStore())
+
+	var wg sync.WaitGroup
+	var reserved int32
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if pool.reserveTask() {
+				atomic.AddInt32(&reserved, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 3 {
+		t.Fatalf("Exactly 3 tasks must be accepted, got %d", reserved)
+	}
+
+	pool.releaseTask()
+
+	// task is not registered because template doesn't exist, so it must not stay counted
+	if _, err := pool.AddTask(db.Task{TemplateID: 1000}, nil, 1); err == nil || err == ErrTaskPoolFull {
+		t.Fatalf("Task must fail on missing template: %v", err)
+	}
+
+	if pool.isFull() {
+		t.Error("Failed task must be released")
+	}
+}
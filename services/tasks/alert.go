@@ -2,25 +2,33 @@ package tasks
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ansible-semaphore/semaphore/lib"
 	"github.com/ansible-semaphore/semaphore/util"
 )
 
-const emailTemplate = "Subject: Task '{{ .Name }}' failed\r\n" +
+const emailTemplate = "Subject: {{ if .Instance }}[{{ .Instance }}] {{ end }}Task '{{ .Name }}' failed\r\n" +
 	"From: {{ .From }}\r\n" +
 	"\r\n" +
 	"Task {{ .TaskID }} with template '{{ .Name }}' has failed!`\n" +
 	"Task Log: {{ .TaskURL }}"
 
-const telegramTemplate = `{"chat_id": "{{ .ChatID }}","parse_mode":"HTML","text":"<code>{{ .Name }}</code>\n#{{ .TaskID }} <b>{{ .TaskResult }}</b> <code>{{ .TaskVersion }}</code> {{ .TaskDescription }}\nby {{ .Author }}\n{{ .TaskURL }}"}`
+const telegramTemplate = `{"chat_id": "{{ .ChatID }}","parse_mode":"HTML","text":"{{ if .Instance }}[{{ .Instance }}] {{ end }}<code>{{ .Name }}</code>\n#{{ .TaskID }} <b>{{ .TaskResult }}</b> <code>{{ .TaskVersion }}</code> {{ .TaskDescription }}\nby {{ .Author }}\n{{ .TaskURL }}"}`
 
-const slackTemplate = `{ "attachments": [ { "title": "Task: {{ .Name }}", "title_link": "{{ .TaskURL }}", "text": "execution ID #{{ .TaskID }}, status: {{ .TaskResult }}!", "color": "{{ .Color }}", "mrkdwn_in": ["text"], "fields": [ { "title": "Author", "value": "{{ .Author }}", "short": true }] } ]}`
+const discordTemplate = `{"content": "{{ if .Instance }}[{{ .Instance }}] {{ end }}Task **{{ .Name }}** #{{ .TaskID }} {{ .TaskResult }} {{ .TaskVersion }} {{ .TaskDescription }}\nby {{ .Author }}\n{{ .TaskURL }}"}`
+
+const msTeamsTemplate = `{"@type": "MessageCard", "@context": "https://schema.org/extensions", "themeColor": "{{ .Color }}", "summary": "{{ if .Instance }}[{{ .Instance }}] {{ end }}Task: {{ .Name }}", "title": "{{ if .Instance }}[{{ .Instance }}] {{ end }}Task: {{ .Name }}", "text": "execution ID #{{ .TaskID }}, status: {{ .TaskResult }}! {{ .TaskVersion }} {{ .TaskDescription }}<br>by {{ .Author }}", "potentialAction": [{"@type": "OpenUri", "name": "Open task", "targets": [{"os": "default", "uri": "{{ .TaskURL }}"}]}]}`
+
+const slackTemplate = `{ "attachments": [ { "title": "{{ if .Instance }}[{{ .Instance }}] {{ end }}Task: {{ .Name }}", "title_link": "{{ .TaskURL }}", "text": "execution ID #{{ .TaskID }}, status: {{ .TaskResult }}!", "color": "{{ .Color }}", "mrkdwn_in": ["text"], "fields": [ { "title": "Author", "value": "{{ .Author }}", "short": true }] } ]}`
 
 // Alert represents an alert that will be templated and sent to the appropriate service
 type Alert struct {
@@ -34,23 +42,92 @@ type Alert struct {
 	Author          string
 	Color           string
 	From            string
+	// Instance is name of Semaphore instance which sent the alert
+	Instance string
+}
+
+var (
+	alertMailerLock sync.Mutex
+	alertMailer     *util.Mailer
+)
+
+// getAlertMailer returns mailer for the current email settings. Mailer is
+// shared by tasks to reuse SMTP connections and recreated if settings change.
+func getAlertMailer(config util.EmailConfig) *util.Mailer {
+	alertMailerLock.Lock()
+	defer alertMailerLock.Unlock()
+
+	if alertMailer == nil || alertMailer.Config() != config {
+		if alertMailer != nil {
+			alertMailer.Close()
+		}
+		alertMailer = util.NewMailer(config)
+	}
+
+	return alertMailer
+}
+
+// taskURL returns link to the task in web UI
+func (t *TaskRunner) taskURL() string {
+	return util.Config.WebHost + "/project/" + strconv.Itoa(t.Template.ProjectID) +
+		"/templates/" + strconv.Itoa(t.Template.ID) +
+		"?t=" + strconv.Itoa(t.Task.ID)
+}
+
+// buildAlert returns alert fields which are common for all channels.
+// Tasks without version show version of the build task.
+func (t *TaskRunner) buildAlert() (Alert, error) {
+	var version string
+	if t.Task.Version != nil {
+		version = *t.Task.Version
+	} else if t.Task.BuildTaskID != nil {
+		if buildVer := t.Task.GetIncomingVersion(t.pool.store); buildVer != nil {
+			version = *buildVer
+		} else {
+			version = "build " + strconv.Itoa(*t.Task.BuildTaskID)
+		}
+	}
+
+	var message string
+	if t.Task.Message != "" {
+		message = "- " + t.Task.Message
+	}
+
+	var author string
+	if t.Task.UserID != nil {
+		user, err := t.pool.store.GetUser(*t.Task.UserID)
+		if err != nil {
+			return Alert{}, err
+		}
+		author = user.Name
+	}
+
+	return Alert{
+		TaskID:          strconv.Itoa(t.Task.ID),
+		Name:            t.Template.Name,
+		TaskURL:         t.taskURL(),
+		TaskResult:      strings.ToUpper(string(t.Task.Status)),
+		TaskVersion:     version,
+		TaskDescription: message,
+		Author:          author,
+		Instance:        util.Config.GetInstanceName(),
+	}, nil
 }
 
 func (t *TaskRunner) sendMailAlert() {
-	if !util.Config.EmailAlert || !t.alert {
+	email := util.Config.GetEmailConfig()
+
+	if !email.IsEnabled() || !t.alert {
 		return
 	}
 
-	mailHost := util.Config.EmailHost + ":" + util.Config.EmailPort
-
 	var mailBuffer bytes.Buffer
 	alert := Alert{
-		TaskID: strconv.Itoa(t.Task.ID),
-		Name:   t.Template.Name,
-		TaskURL: util.Config.WebHost + "/project/" + strconv.Itoa(t.Template.ProjectID) +
-			"/templates/" + strconv.Itoa(t.Template.ID) +
-			"?t=" + strconv.Itoa(t.Task.ID),
-		From: util.Config.EmailSender,
+		TaskID:   strconv.Itoa(t.Task.ID),
+		Name:     t.Template.Name,
+		TaskURL:  t.taskURL(),
+		From:     email.Sender,
+		Instance: util.Config.GetInstanceName(),
 	}
 	tpl := template.New("mail body template")
 	tpl, err := tpl.Parse(emailTemplate)
@@ -58,6 +135,8 @@ func (t *TaskRunner) sendMailAlert() {
 
 	t.panicOnError(tpl.Execute(&mailBuffer, alert), "Can't generate alert template!")
 
+	mailer := getAlertMailer(email)
+
 	for _, user := range t.users {
 		userObj, err2 := t.pool.store.GetUser(user)
 
@@ -70,13 +149,9 @@ func (t *TaskRunner) sendMailAlert() {
 			continue
 		}
 
-		if util.Config.EmailSecure {
-			err2 = util.SendSecureMail(util.Config.EmailHost, util.Config.EmailPort,
-				util.Config.EmailSender, util.Config.EmailUsername, util.Config.EmailPassword,
-				userObj.Email, mailBuffer)
-		} else {
-			err2 = util.SendMail(mailHost, util.Config.EmailSender, userObj.Email, mailBuffer)
-		}
+		err2 = retryAlert(func() error {
+			return mailer.Send(userObj.Email, mailBuffer)
+		})
 
 		if err2 != nil {
 			util.LogError(err2)
@@ -85,7 +160,14 @@ func (t *TaskRunner) sendMailAlert() {
 }
 
 func (t *TaskRunner) sendTelegramAlert() {
-	if !util.Config.TelegramAlert || !t.alert {
+	telegram, err := util.Config.GetTelegramConfig()
+
+	if !telegram.IsEnabled() || !t.alert {
+		return
+	}
+
+	if err != nil {
+		t.Log("Can't send telegram alert! Error: " + err.Error())
 		return
 	}
 
@@ -93,7 +175,7 @@ func (t *TaskRunner) sendTelegramAlert() {
 		return
 	}
 
-	chatID := util.Config.TelegramChat
+	chatID := telegram.Chat
 	if t.alertChat != nil && *t.alertChat != "" {
 		chatID = *t.alertChat
 	}
@@ -104,46 +186,16 @@ func (t *TaskRunner) sendTelegramAlert() {
 
 	var telegramBuffer bytes.Buffer
 
-	var version string
-	if t.Task.Version != nil {
-		version = *t.Task.Version
-	} else if t.Task.BuildTaskID != nil {
-		buildVer := t.Task.GetIncomingVersion(t.pool.store)
-		if buildVer != nil {
-			version = *buildVer
-		}
-	} else {
-		version = ""
-	}
-
-	var message string
-	if t.Task.Message != "" {
-		message = "- " + t.Task.Message
-	}
-
-	var author string
-	if t.Task.UserID != nil {
-		user, err := t.pool.store.GetUser(*t.Task.UserID)
-		if err != nil {
-			panic(err)
-		}
-		author = user.Name
-	}
-
-	alert := Alert{
-		TaskID:          strconv.Itoa(t.Task.ID),
-		Name:            t.Template.Name,
-		TaskURL:         util.Config.WebHost + "/project/" + strconv.Itoa(t.Template.ProjectID) + "/templates/" + strconv.Itoa(t.Template.ID) + "?t=" + strconv.Itoa(t.Task.ID),
-		ChatID:          chatID,
-		TaskResult:      strings.ToUpper(string(t.Task.Status)),
-		TaskVersion:     version,
-		TaskDescription: message,
-		Author:          author,
+	alert, err := t.buildAlert()
+	if err != nil {
+		t.Log("Can't send telegram alert! Error: " + err.Error())
+		return
 	}
+	alert.ChatID = chatID
 
 	tpl := template.New("telegram body template")
 
-	tpl, err := tpl.Parse(telegramTemplate)
+	tpl, err = tpl.Parse(telegramTemplate)
 	if err != nil {
 		t.Log("Can't parse telegram template!")
 		panic(err)
@@ -155,73 +207,39 @@ func (t *TaskRunner) sendTelegramAlert() {
 		panic(err)
 	}
 
-	httpTransport := &http.Transport{}
-	if len(util.Config.AlertUrlProxy) != 0 { // Set the proxy only if the proxy param is specified
-		alertUrlProxy, proxyErr := url.Parse(util.Config.AlertUrlProxy)
-		if proxyErr == nil {
-			httpTransport.Proxy = http.ProxyURL(alertUrlProxy)
-		}
-		if proxyErr != nil {
-			t.Log("Can't send slack alert! Error: " + proxyErr.Error())
-		}
-	}
-	http := http.Client{Transport: httpTransport}
+	http := t.alertHTTPClient()
 
-	resp, err := http.Post("https://api.telegram.org/bot"+util.Config.TelegramToken+"/sendMessage", "application/json", &telegramBuffer)
+	err = postAlert(http, "https://api.telegram.org/bot"+telegram.Token+"/sendMessage", telegramBuffer.Bytes())
 
 	if err != nil {
 		t.Log("Can't send telegram alert! Error: " + err.Error())
-	} else if resp.StatusCode != 200 {
-		t.Log("Can't send telegram alert! Response code: " + strconv.Itoa(resp.StatusCode))
 	}
 }
 
 func (t *TaskRunner) sendSlackAlert() {
-	if !util.Config.SlackAlert || !t.alert {
+	slack, err := util.Config.GetSlackConfig()
+
+	if !slack.IsEnabled() || !t.alert {
 		return
 	}
 
-	if t.Template.SuppressSuccessAlerts && t.Task.Status == lib.TaskSuccessStatus {
+	if err != nil {
+		t.Log("Can't send slack alert! Error: " + err.Error())
 		return
 	}
 
-	slackUrl := util.Config.SlackUrl
-
-	httpTransport := &http.Transport{}
-	if len(util.Config.AlertUrlProxy) != 0 { // Set the proxy only if the proxy param is specified
-		alertUrlProxy, proxyErr := url.Parse(util.Config.AlertUrlProxy)
-		if proxyErr == nil {
-			httpTransport.Proxy = http.ProxyURL(alertUrlProxy)
-		}
-		if proxyErr != nil {
-			t.Log("Can't send slack alert! Error: " + proxyErr.Error())
-		}
+	if t.Template.SuppressSuccessAlerts && t.Task.Status == lib.TaskSuccessStatus {
+		return
 	}
-	http := http.Client{Transport: httpTransport}
 
-	var slackBuffer bytes.Buffer
-
-	var version string
-	if t.Task.Version != nil {
-		version = *t.Task.Version
-	} else if t.Task.BuildTaskID != nil {
-		version = "build " + strconv.Itoa(*t.Task.BuildTaskID)
-	} else {
-		version = ""
-	}
+	http := t.alertHTTPClient()
 
-	var message string
-	if t.Task.Message != "" {
-		message = "- " + t.Task.Message
-	}
+	var slackBuffer bytes.Buffer
 
-	var author string
-	if t.Task.UserID != nil {
-		user, err := t.pool.store.GetUser(*t.Task.UserID)
-		if err != nil {
-			panic(err)
-		}
-		author = user.Name
+	alert, err := t.buildAlert()
+	if err != nil {
+		t.Log("Can't send slack alert! Error: " + err.Error())
+		return
 	}
 
 	var color string
@@ -238,20 +256,11 @@ func (t *TaskRunner) sendSlackAlert() {
 	} else if t.Task.Status == lib.TaskStoppedStatus {
 		color = "#5B5B5B"
 	}
-	alert := Alert{
-		TaskID:          strconv.Itoa(t.Task.ID),
-		Name:            t.Template.Name,
-		TaskURL:         util.Config.WebHost + "/project/" + strconv.Itoa(t.Template.ProjectID) + "/templates/" + strconv.Itoa(t.Template.ID) + "?t=" + strconv.Itoa(t.Task.ID),
-		TaskResult:      strings.ToUpper(string(t.Task.Status)),
-		TaskVersion:     version,
-		TaskDescription: message,
-		Author:          author,
-		Color:           color,
-	}
+	alert.Color = color
 
 	tpl := template.New("slack body template")
 
-	tpl, err := tpl.Parse(slackTemplate)
+	tpl, err = tpl.Parse(slackTemplate)
 	if err != nil {
 		t.Log("Can't parse slack template!")
 		panic(err)
@@ -262,11 +271,235 @@ func (t *TaskRunner) sendSlackAlert() {
 		t.Log("Can't generate alert template!")
 		panic(err)
 	}
-	resp, err := http.Post(slackUrl, "application/json", &slackBuffer)
+	for _, slackUrl := range slack.Urls {
+		err = postAlert(http, slackUrl, slackBuffer.Bytes())
+
+		if err != nil {
+			t.Log("Can't send slack alert! Error: " + err.Error())
+		}
+	}
+}
+
+func (t *TaskRunner) sendDiscordAlert() {
+	discord, err := util.Config.GetDiscordConfig()
+
+	if !discord.IsEnabled() || !t.alert {
+		return
+	}
 
 	if err != nil {
-		t.Log("Can't send slack alert! Error: " + err.Error())
-	} else if resp.StatusCode != 200 {
-		t.Log("Can't send slack alert! Response code: " + strconv.Itoa(resp.StatusCode))
+		t.Log("Can't send discord alert! Error: " + err.Error())
+		return
+	}
+
+	if t.Template.SuppressSuccessAlerts && t.Task.Status == lib.TaskSuccessStatus {
+		return
+	}
+
+	alert, err := t.buildAlert()
+	if err != nil {
+		t.Log("Can't send discord alert! Error: " + err.Error())
+		return
+	}
+
+	tpl, err := template.New("discord body template").Parse(discordTemplate)
+	if err != nil {
+		t.Log("Can't parse discord template!")
+		panic(err)
+	}
+
+	var discordBuffer bytes.Buffer
+	err = tpl.Execute(&discordBuffer, alert)
+	if err != nil {
+		t.Log("Can't generate alert template!")
+		panic(err)
+	}
+
+	err = postAlert(t.alertHTTPClient(), discord.Url, discordBuffer.Bytes())
+
+	if err != nil {
+		t.Log("Can't send discord alert! Error: " + err.Error())
+	}
+}
+
+func (t *TaskRunner) sendMsTeamsAlert() {
+	teams, err := util.Config.GetMsTeamsConfig()
+
+	if !teams.IsEnabled() || !t.alert {
+		return
+	}
+
+	if err != nil {
+		t.Log("Can't send microsoft teams alert! Error: " + err.Error())
+		return
+	}
+
+	if t.Template.SuppressSuccessAlerts && t.Task.Status == lib.TaskSuccessStatus {
+		return
+	}
+
+	alert, err := t.buildAlert()
+	if err != nil {
+		t.Log("Can't send microsoft teams alert! Error: " + err.Error())
+		return
+	}
+
+	alert.Color = "FF0000"
+	if t.Task.Status == lib.TaskSuccessStatus {
+		alert.Color = "00FF00"
+	}
+
+	tpl, err := template.New("microsoft teams body template").Parse(msTeamsTemplate)
+	if err != nil {
+		t.Log("Can't parse microsoft teams template!")
+		panic(err)
+	}
+
+	var teamsBuffer bytes.Buffer
+	err = tpl.Execute(&teamsBuffer, alert)
+	if err != nil {
+		t.Log("Can't generate alert template!")
+		panic(err)
 	}
+
+	err = postAlert(t.alertHTTPClient(), teams.Url, teamsBuffer.Bytes())
+
+	if err != nil {
+		t.Log("Can't send microsoft teams alert! Error: " + err.Error())
+	}
+}
+
+// WebhookAlert is JSON payload posted by generic webhook alerting
+type WebhookAlert struct {
+	TaskID     int    `json:"task_id"`
+	TemplateID int    `json:"template_id"`
+	ProjectID  int    `json:"project_id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	TaskURL    string `json:"task_url"`
+	Version    string `json:"version,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Instance   string `json:"instance,omitempty"`
+}
+
+func (t *TaskRunner) sendWebhookAlert() {
+	webhook, err := util.Config.GetWebhookConfig()
+
+	if !webhook.IsEnabled() || !t.alert {
+		return
+	}
+
+	if err != nil {
+		t.Log("Can't send webhook alert! Error: " + err.Error())
+		return
+	}
+
+	if t.Template.SuppressSuccessAlerts && t.Task.Status == lib.TaskSuccessStatus {
+		return
+	}
+
+	base, err := t.buildAlert()
+	if err != nil {
+		t.Log("Can't send webhook alert! Error: " + err.Error())
+		return
+	}
+
+	alert := WebhookAlert{
+		TaskID:     t.Task.ID,
+		TemplateID: t.Template.ID,
+		ProjectID:  t.Template.ProjectID,
+		Name:       t.Template.Name,
+		Status:     string(t.Task.Status),
+		TaskURL:    base.TaskURL,
+		Version:    base.TaskVersion,
+		Message:    t.Task.Message,
+		Author:     base.Author,
+		Instance:   base.Instance,
+	}
+
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		t.Log("Can't generate alert payload!")
+		panic(err)
+	}
+
+	http := t.alertHTTPClient()
+
+	for _, webhookUrl := range webhook.Urls {
+		err = postAlertWithHeaders(http, webhookUrl, payload, webhook.Headers)
+
+		if err != nil {
+			t.Log("Can't send webhook alert! Error: " + err.Error())
+		}
+	}
+}
+
+// alertHTTPClient returns client for alert webhooks which uses AlertUrlProxy if it is set
+func (t *TaskRunner) alertHTTPClient() *http.Client {
+	httpTransport := &http.Transport{}
+	if len(util.Config.AlertUrlProxy) != 0 { // Set the proxy only if the proxy param is specified
+		alertUrlProxy, proxyErr := url.Parse(util.Config.AlertUrlProxy)
+		if proxyErr == nil {
+			httpTransport.Proxy = http.ProxyURL(alertUrlProxy)
+		}
+		if proxyErr != nil {
+			t.Log("Can't use alert proxy! Error: " + proxyErr.Error())
+		}
+	}
+	return util.BuildHTTPClient(httpTransport)
+}
+
+// maxAlertRetryDelay limits delay between attempts to deliver an alert
+const maxAlertRetryDelay = 5 * time.Minute
+
+// retryAlert calls send until it succeeds or number of retries
+// configured by AlertRetryCount is exceeded. Delay between attempts
+// starts from AlertRetryDelaySeconds and doubles each time up to maxAlertRetryDelay.
+func retryAlert(send func() error) (err error) {
+	delay := util.Config.GetAlertRetryDelay()
+
+	for attempt := 0; ; attempt++ {
+		err = send()
+		if err == nil || attempt >= util.Config.GetAlertRetryCount() {
+			return
+		}
+		if delay > maxAlertRetryDelay {
+			delay = maxAlertRetryDelay
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// postAlert posts JSON payload of the alert retrying on failure
+func postAlert(client *http.Client, url string, payload []byte) error {
+	return postAlertWithHeaders(client, url, payload, nil)
+}
+
+// postAlertWithHeaders posts JSON payload of the alert with additional headers retrying on failure
+func postAlertWithHeaders(client *http.Client, url string, payload []byte, headers map[string]string) error {
+	return retryAlert(func() error {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() //nolint:errcheck
+
+		// Discord webhooks respond with 204 No Content
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("response code: %d", resp.StatusCode)
+		}
+		return nil
+	})
 }
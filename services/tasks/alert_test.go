@@ -0,0 +1,182 @@
+package tasks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ansible-semaphore/semaphore/db"
+	"github.com/ansible-semaphore/semaphore/db/bolt"
+	"github.com/ansible-semaphore/semaphore/lib"
+	"github.com/ansible-semaphore/semaphore/util"
+)
+
+// newAlertTestServer starts server which stores bodies of received alerts
+func newAlertTestServer(t *testing.T, received *[][]byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		*received = append(*received, body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newAlertTestRunner() *TaskRunner {
+	return &TaskRunner{
+		Task:     db.Task{ID: 3, Status: lib.TaskFailStatus},
+		Template: db.Template{ID: 2, ProjectID: 1, Name: "Deploy"},
+		alert:    true,
+		pool:     &TaskPool{},
+	}
+}
+
+func TestSendWebhookAlertToAllUrls(t *testing.T) {
+	var first, second [][]byte
+	firstSrv := newAlertTestServer(t, &first)
+	secondSrv := newAlertTestServer(t, &second)
+
+	util.Config = &util.ConfigType{
+		WebhookAlert: true,
+		WebhookUrl:   firstSrv.URL,
+		WebhookUrls:  []string{secondSrv.URL, firstSrv.URL},
+	}
+
+	newAlertTestRunner().sendWebhookAlert()
+
+	if len(first) != 1 || len(second) != 1 {
+		t.Fatalf("Alert must be sent once to each webhook, got %d and %d", len(first), len(second))
+	}
+
+	var alert WebhookAlert
+	if err := json.Unmarshal(second[0], &alert); err != nil {
+		t.Fatal(err)
+	}
+	if alert.TaskID != 3 || alert.Name != "Deploy" {
+		t.Errorf("Invalid alert payload: %s", second[0])
+	}
+}
+
+func TestSendAlertsWithInstanceName(t *testing.T) {
+	var webhook, slack [][]byte
+	webhookSrv := newAlertTestServer(t, &webhook)
+	slackSrv := newAlertTestServer(t, &slack)
+
+	util.Config = &util.ConfigType{
+		InstanceName: "prod-1",
+		WebhookAlert: true,
+		WebhookUrl:   webhookSrv.URL,
+		SlackAlert:   true,
+		SlackUrl:     slackSrv.URL,
+	}
+
+	runner := newAlertTestRunner()
+	runner.sendWebhookAlert()
+	runner.sendSlackAlert()
+
+	if len(webhook) != 1 || len(slack) != 1 {
+		t.Fatalf("Alerts were not sent, got %d webhook and %d slack alerts", len(webhook), len(slack))
+	}
+
+	var alert WebhookAlert
+	if err := json.Unmarshal(webhook[0], &alert); err != nil {
+		t.Fatal(err)
+	}
+	if alert.Instance != "prod-1" {
+		t.Errorf("Webhook alert must contain instance name: %s", webhook[0])
+	}
+
+	if !strings.Contains(string(slack[0]), `"title": "[prod-1] Task: Deploy"`) {
+		t.Errorf("Slack alert must contain instance name: %s", slack[0])
+	}
+}
+
+func TestSetStatusSendsAlertsInBackground(t *testing.T) {
+	unblock := make(chan struct{})
+	received := make(chan struct{}, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		received <- struct{}{}
+	}))
+	defer srv.Close()
+	defer close(unblock)
+
+	util.Config = &util.ConfigType{
+		WebhookAlert: true,
+		WebhookUrl:   srv.URL,
+	}
+
+	store := bolt.CreateTestStore()
+	task, err := store.CreateTask(db.Task{TemplateID: 2, ProjectID: 1, Status: lib.TaskRunningStatus})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runner := newAlertTestRunner()
+	runner.Task = task
+	runner.pool = &TaskPool{store: store}
+
+	done := make(chan struct{})
+	go func() {
+		runner.SetStatus(lib.TaskFailStatus)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SetStatus must not wait for alert delivery")
+	}
+
+	unblock <- struct{}{}
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Alert was not sent")
+	}
+}
+
+func TestSendAlertsWithUnknownAuthor(t *testing.T) {
+	var webhook, slack, discord, teams [][]byte
+	webhookSrv := newAlertTestServer(t, &webhook)
+	slackSrv := newAlertTestServer(t, &slack)
+	discordSrv := newAlertTestServer(t, &discord)
+	teamsSrv := newAlertTestServer(t, &teams)
+
+	util.Config = &util.ConfigType{
+		WebhookAlert: true,
+		WebhookUrl:   webhookSrv.URL,
+		SlackAlert:   true,
+		SlackUrl:     slackSrv.URL,
+		DiscordAlert: true,
+		DiscordUrl:   discordSrv.URL,
+		MsTeamsAlert: true,
+		MsTeamsUrl:   teamsSrv.URL,
+	}
+
+	userID := 100
+
+	runner := newAlertTestRunner()
+	runner.Task.UserID = &userID
+	runner.pool = &TaskPool{store: bolt.CreateTestStore(), logger: make(chan logRecord, 10)}
+
+	runner.sendWebhookAlert()
+	runner.sendSlackAlert()
+	runner.sendDiscordAlert()
+	runner.sendMsTeamsAlert()
+
+	if len(webhook) != 0 || len(slack) != 0 || len(discord) != 0 || len(teams) != 0 {
+		t.Errorf("Alerts must not be sent if author can't be loaded")
+	}
+	if len(runner.pool.logger) != 4 {
+		t.Errorf("Each failed alert must be logged, got %d records", len(runner.pool.logger))
+	}
+}
@@ -2,11 +2,14 @@ package util
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
@@ -14,16 +17,29 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/BurntSushi/toml"
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/go-github/github"
 	"github.com/gorilla/securecookie"
+	"gopkg.in/yaml.v3"
 )
 
 // Cookie is a runtime generated secure cookie used for authentication
 var Cookie *securecookie.SecureCookie
 
+// CookieCodecs are codecs used to decode cookies. First one is Cookie,
+// the rest are built from CookieVerifyKeys.
+var CookieCodecs []securecookie.Codec
+
 // WebHostURL is the public route to the semaphore server
 var WebHostURL *url.URL
 
@@ -37,10 +53,42 @@ type DbConfig struct {
 	Dialect string `json:"-"`
 
 	Hostname string            `json:"host" env:"SEMAPHORE_DB_HOST"`
+	Port     int               `json:"port,omitempty"` // used if Hostname doesn't contain a port
 	Username string            `json:"user" env:"SEMAPHORE_DB_USER"`
 	Password string            `json:"pass" env:"SEMAPHORE_DB_PASS"`
 	DbName   string            `json:"name" env:"SEMAPHORE_DB"`
 	Options  map[string]string `json:"options"`
+
+	// StatementTimeoutSeconds aborts queries running longer than the timeout.
+	// 0 means no timeout.
+	StatementTimeoutSeconds int `json:"statement_timeout_seconds,omitempty" env:"SEMAPHORE_DB_STATEMENT_TIMEOUT_SECONDS"`
+
+	// ApplicationName labels connections in pg_stat_activity.
+	// Used only by Postgres, MySQL driver doesn't support connection attributes.
+	ApplicationName string `json:"application_name,omitempty" default:"semaphore" env:"SEMAPHORE_DB_APPLICATION_NAME"`
+
+	// Connection pool settings. MaxOpenConns 0 means unlimited,
+	// ConnMaxLifetime is a duration like "30m", empty or "0" means connections are reused forever.
+	MaxOpenConns    int    `json:"max_open_conns,omitempty" env:"SEMAPHORE_DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int    `json:"max_idle_conns,omitempty" default:"2" env:"SEMAPHORE_DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime string `json:"conn_max_lifetime,omitempty" env:"SEMAPHORE_DB_CONN_MAX_LIFETIME"`
+
+	// TLS parameters of Postgres connection. They override the same keys of Options.
+	// SslMode is not set by default, so the driver default is used.
+	SslMode     string `json:"sslmode,omitempty" env:"SEMAPHORE_DB_SSLMODE"`
+	SslRootCert string `json:"sslrootcert,omitempty" env:"SEMAPHORE_DB_SSLROOTCERT"`
+	SslCert     string `json:"sslcert,omitempty" env:"SEMAPHORE_DB_SSLCERT"`
+	SslKey      string `json:"sslkey,omitempty" env:"SEMAPHORE_DB_SSLKEY"`
+
+	// TLS parameters of MySQL connection. TLSMode overrides "tls" key of Options.
+	// CA and client certificate are registered in the driver as custom TLS config.
+	TLSMode string `json:"tls_mode,omitempty" env:"SEMAPHORE_DB_TLS_MODE"`
+	TLSCA   string `json:"tls_ca,omitempty" env:"SEMAPHORE_DB_TLS_CA"`
+	TLSCert string `json:"tls_cert,omitempty" env:"SEMAPHORE_DB_TLS_CERT"`
+	TLSKey  string `json:"tls_key,omitempty" env:"SEMAPHORE_DB_TLS_KEY"`
+
+	// RequireTLS is copied from ConfigType.RequireDbTLS by GetDBConfig.
+	RequireTLS bool `json:"-"`
 }
 
 type ldapMappings struct {
@@ -48,6 +96,8 @@ type ldapMappings struct {
 	Mail string `json:"mail"`
 	UID  string `json:"uid"`
 	CN   string `json:"cn"`
+	// MemberOf is attribute which contains DNs of user groups, usually `memberOf`
+	MemberOf string `json:"member_of"`
 }
 
 type oidcEndpoint struct {
@@ -59,21 +109,72 @@ type oidcEndpoint struct {
 	Algorithms  []string `json:"algorithms"`
 }
 
+// OidcProvider settings. Env tags are used only for the provider
+// configured from environment, see loadOidcEnvironmentToObject.
 type OidcProvider struct {
-	ClientID      string       `json:"client_id"`
-	ClientSecret  string       `json:"client_secret"`
-	RedirectURL   string       `json:"redirect_url"`
-	Scopes        []string     `json:"scopes"`
-	DisplayName   string       `json:"display_name"`
+	ClientID      string       `json:"client_id" env:"SEMAPHORE_OIDC_CLIENT_ID"`
+	ClientSecret  string       `json:"client_secret" env:"SEMAPHORE_OIDC_CLIENT_SECRET"`
+	RedirectURL   string       `json:"redirect_url" env:"SEMAPHORE_OIDC_REDIRECT_URL"`
+	Scopes        []string     `json:"scopes" env:"SEMAPHORE_OIDC_SCOPES"`
+	DisplayName   string       `json:"display_name" env:"SEMAPHORE_OIDC_DISPLAY_NAME"`
 	Color         string       `json:"color"`
 	Icon          string       `json:"icon"`
-	AutoDiscovery string       `json:"provider_url"`
+	AutoDiscovery string       `json:"provider_url" env:"SEMAPHORE_OIDC_PROVIDER_URL"`
 	Endpoint      oidcEndpoint `json:"endpoint"`
-	UsernameClaim string       `json:"username_claim" default:"preferred_username"`
-	NameClaim     string       `json:"name_claim" default:"preferred_username"`
-	EmailClaim    string       `json:"email_claim" default:"email"`
+	UsernameClaim string       `json:"username_claim" default:"preferred_username" env:"SEMAPHORE_OIDC_USERNAME_CLAIM"`
+	NameClaim     string       `json:"name_claim" default:"preferred_username" env:"SEMAPHORE_OIDC_NAME_CLAIM"`
+	EmailClaim    string       `json:"email_claim" default:"email" env:"SEMAPHORE_OIDC_EMAIL_CLAIM"`
+	// ExpectedIssuer is compared with `iss` claim of tokens.
+	// Defaults to Endpoint.IssuerURL. Required if AutoDiscovery is not used.
+	ExpectedIssuer string `json:"expected_issuer"`
+	// ResponseType is sent as response_type of authorization request.
+	// Only "code" is supported: hybrid flow requires nonce validation.
+	ResponseType string `json:"response_type" default:"code"`
+	// ResponseMode is sent as response_mode of authorization request if set.
+	// "form_post" requires HTTPS because the state cookie must be sent cross-site.
+	ResponseMode string `json:"response_mode"`
+	// UsePKCE enables Proof Key for Code Exchange. Public clients have no
+	// client secret, so it is enabled for them by default.
+	UsePKCE bool `json:"pkce" env:"SEMAPHORE_OIDC_PKCE"`
+}
+
+const (
+	OidcResponseModeQuery    = "query"
+	OidcResponseModeFormPost = "form_post"
+)
+
+var oidcResponseTypes = []string{"code"}
+var oidcResponseModes = []string{"", OidcResponseModeQuery, OidcResponseModeFormPost}
+
+// GetExpectedIssuer returns issuer which tokens of the provider must have
+func (p *OidcProvider) GetExpectedIssuer() string {
+	if p.ExpectedIssuer != "" {
+		return p.ExpectedIssuer
+	}
+	return p.Endpoint.IssuerURL
 }
 
+const (
+	OidcAccountLinkingEmail    = "email"
+	OidcAccountLinkingUsername = "username"
+	OidcAccountLinkingNone     = "none"
+)
+
+const (
+	TaskOverflowQueue  = "queue"
+	TaskOverflowReject = "reject"
+)
+
+const (
+	// ConfigPrecedenceEnv makes environment variables override values from the config file
+	ConfigPrecedenceEnv = "env"
+	// ConfigPrecedenceFile makes environment variables fill only fields which are empty in the config file
+	ConfigPrecedenceFile = "file"
+)
+
+// GitClientId identifies Git client implementation
+type GitClientId string
+
 const (
 	// GoGitClientId is builtin Git client. It is not require external dependencies and is preferred.
 	// Use it if you don't need external SSH authorization.
@@ -107,12 +208,25 @@ type RunnerSettings struct {
 
 // ConfigType mapping between Config and the json file that sets it
 type ConfigType struct {
+	// ConfigVersion is version of the config schema.
+	// Configs of older versions are migrated on load, see configMigrations.
+	ConfigVersion int `json:"config_version"`
+
 	MySQL    DbConfig `json:"mysql"`
 	BoltDb   DbConfig `json:"bolt"`
 	Postgres DbConfig `json:"postgres"`
 
 	Dialect string `json:"dialect" rule:"^mysql|bolt|postgres$" env:"SEMAPHORE_DB_DIALECT"`
 
+	// DbLogQueries enables logging of SQL queries (without arguments).
+	// If DbSlowQueryThresholdMs is set, only queries slower than the threshold are logged.
+	DbLogQueries           bool `json:"db_log_queries" env:"SEMAPHORE_DB_LOG_QUERIES"`
+	DbSlowQueryThresholdMs int  `json:"db_slow_query_threshold_ms" rule:"^[0-9]{1,9}$" env:"SEMAPHORE_DB_SLOW_QUERY_THRESHOLD_MS"`
+
+	// RequireDbTLS forces encrypted connection to the database.
+	// Not supported by BoltDB.
+	RequireDbTLS bool `json:"require_db_tls" env:"SEMAPHORE_REQUIRE_DB_TLS"`
+
 	// Format `:port_num` eg, :3000
 	// if : is missing it will be corrected
 	Port string `json:"port" default:":3000" rule:"^:?([0-9]{1,5})$" env:"SEMAPHORE_PORT"`
@@ -126,19 +240,53 @@ type ConfigType struct {
 
 	// SshConfigPath is a path to the custom SSH config file.
 	// Default path is ~/.ssh/config.
-	SshConfigPath string `json:"ssh_config_path" env:"SEMAPHORE_TMP_PATH"`
+	SshConfigPath string `json:"ssh_config_path" env:"SEMAPHORE_SSH_CONFIG_PATH"`
 
 	GitClientId string `json:"git_client" rule:"^go_git|cmd_git$" env:"SEMAPHORE_GIT_CLIENT" default:"cmd_git"`
 
+	// ProjectGitClient overrides GitClientId for specific projects (by project ID).
+	ProjectGitClient map[int]GitClientId `json:"project_git_client"`
+
 	// web host
 	WebHost string `json:"web_host" env:"SEMAPHORE_WEB_ROOT"`
 
 	// cookie hashing & encryption
 	CookieHash       string `json:"cookie_hash" env:"SEMAPHORE_COOKIE_HASH"`
 	CookieEncryption string `json:"cookie_encryption" env:"SEMAPHORE_COOKIE_ENCRYPTION"`
+	// CookieVerifyKeys are previous cookie keys in format `hash` or `hash:encryption`.
+	// They are used only to decode cookies, e.g. during key rotation or
+	// blue/green deployment. New cookies are always encoded by CookieHash/CookieEncryption.
+	CookieVerifyKeys []string `json:"cookie_verify_keys" env:"SEMAPHORE_COOKIE_VERIFY_KEYS"`
+	// CookieIdleTimeoutSeconds expires sessions which were not used for this time.
+	// Sessions have no absolute max age, active sessions live until logout.
+	CookieIdleTimeoutSeconds int `json:"cookie_idle_timeout_seconds" default:"604800" rule:"^[0-9]{1,10}$" env:"SEMAPHORE_COOKIE_IDLE_TIMEOUT_SECONDS"`
+	// CookieMaxLength overrides max length of encoded cookie. 0 means 4096.
+	CookieMaxLength int `json:"cookie_max_length" rule:"^[0-9]{1,6}$" env:"SEMAPHORE_COOKIE_MAX_LENGTH"`
+	// CookieSerializer is "gob" or "json". Changing it invalidates existing sessions.
+	CookieSerializer string `json:"cookie_serializer" default:"gob" rule:"^(|gob|json)$" env:"SEMAPHORE_COOKIE_SERIALIZER"`
 	// AccessKeyEncryption is BASE64 encoded byte array used
 	// for encrypting and decrypting access keys stored in database.
 	AccessKeyEncryption string `json:"access_key_encryption" env:"SEMAPHORE_ACCESS_KEY_ENCRYPTION"`
+	// AccessKeyEncryptionOld are previous access key encryption keys. They are used
+	// only to decrypt access keys until `vault rekey` re-encrypts them by AccessKeyEncryption.
+	AccessKeyEncryptionOld []string `json:"access_key_encryption_old" env:"SEMAPHORE_ACCESS_KEY_ENCRYPTION_OLD"`
+	// SecretsFile is a path to JSON file with secret keys. Keys from the file
+	// override keys of the main config. It is loaded right after the main config file.
+	SecretsFile string `json:"secrets_file,omitempty"`
+	// Vault loads sensitive fields from HashiCorp Vault after file and environment.
+	Vault VaultConfig `json:"vault"`
+
+	// DevMode relaxes checks which are mandatory in production,
+	// e.g. allows empty secret keys.
+	DevMode bool `json:"dev_mode" env:"SEMAPHORE_DEV_MODE"`
+
+	// LenientSecretValidation only warns about secret keys which are
+	// not valid base64 or have wrong length. Use it for migration only.
+	LenientSecretValidation bool `json:"lenient_secret_validation" env:"SEMAPHORE_LENIENT_SECRET_VALIDATION"`
+
+	// RequireAccessKeyEncryption rejects empty AccessKeyEncryption even in dev mode,
+	// otherwise only a warning is logged because access keys are stored unencrypted.
+	RequireAccessKeyEncryption bool `json:"require_access_key_encryption" env:"SEMAPHORE_REQUIRE_ACCESS_KEY_ENCRYPTION"`
 
 	// email alerting
 	EmailAlert    bool   `json:"email_alert" env:"SEMAPHORE_EMAIL_ALERT"`
@@ -149,6 +297,11 @@ type ConfigType struct {
 	EmailPassword string `json:"email_password" env:"SEMAPHORE_EMAIL_PASSWORD"`
 	EmailSecure   bool   `json:"email_secure" env:"SEMAPHORE_EMAIL_SECURE"`
 
+	// EmailPoolSize is a number of SMTP connections kept open for reuse.
+	// EmailKeepAliveSeconds is how long an idle SMTP connection is kept open.
+	EmailPoolSize         int `json:"email_pool_size" default:"1" rule:"^[0-9]{1,4}$" env:"SEMAPHORE_EMAIL_POOL_SIZE"`
+	EmailKeepAliveSeconds int `json:"email_keep_alive_seconds" rule:"^[0-9]{1,6}$" env:"SEMAPHORE_EMAIL_KEEP_ALIVE_SECONDS"`
+
 	// ldap settings
 	LdapEnable       bool         `json:"ldap_enable" env:"SEMAPHORE_LDAP_ENABLE"`
 	LdapBindDN       string       `json:"ldap_binddn" env:"SEMAPHORE_LDAP_BIND_DN"`
@@ -158,6 +311,27 @@ type ConfigType struct {
 	LdapSearchFilter string       `json:"ldap_searchfilter" env:"SEMAPHORE_LDAP_SEARCH_FILTER"`
 	LdapMappings     ldapMappings `json:"ldap_mappings"`
 	LdapNeedTLS      bool         `json:"ldap_needtls" env:"SEMAPHORE_LDAP_NEEDTLS"`
+	// LdapNeedTLS connects with LDAPS, LdapUseStartTLS upgrades plain connection
+	// with StartTLS. They can't be enabled together.
+	LdapUseStartTLS bool `json:"ldap_use_starttls" env:"SEMAPHORE_LDAP_USE_STARTTLS"`
+	// LdapServers are failover servers (host:port) tried in order after LdapServer
+	LdapServers []string `json:"ldap_servers" env:"SEMAPHORE_LDAP_HOSTS"`
+
+	// LdapGroupMappings maps LDAP group DNs to project roles.
+	// Group DNs contain commas, so in env the value is set as JSON object.
+	LdapGroupMappings map[string]string `json:"ldap_group_mappings" env:"SEMAPHORE_LDAP_GROUP_MAPPINGS"`
+
+	// InstanceName distinguishes alerts of several Semaphore instances.
+	// Defaults to hostname.
+	InstanceName string `json:"instance_name" rule:"^[a-zA-Z0-9 ._-]{0,64}$" env:"SEMAPHORE_INSTANCE_NAME"`
+
+	// UserAgent is sent in outbound HTTP requests. Defaults to Semaphore/<version>.
+	UserAgent string `json:"user_agent" rule:"^[ -~]{0,256}$" env:"SEMAPHORE_USER_AGENT"`
+
+	// AlertRetryCount is number of additional attempts to deliver a failed alert.
+	// AlertRetryDelaySeconds is a delay before the first retry, doubled for each next one.
+	AlertRetryCount        int `json:"alert_retry_count" default:"2" rule:"^[0-9]{1,2}$" env:"SEMAPHORE_ALERT_RETRY_COUNT"`
+	AlertRetryDelaySeconds int `json:"alert_retry_delay_seconds" default:"1" rule:"^[0-9]{1,4}$" env:"SEMAPHORE_ALERT_RETRY_DELAY_SECONDS"`
 
 	// telegram and slack alerting
 	AlertUrlProxy string `json:"alert_url_proxy" env:"SEMAPHORE_ALERT_PROXY_URL"`
@@ -166,12 +340,59 @@ type ConfigType struct {
 	TelegramToken string `json:"telegram_token" env:"SEMAPHORE_TELEGRAM_TOKEN"`
 	SlackAlert    bool   `json:"slack_alert" env:"SEMAPHORE_SLACK_ALERT"`
 	SlackUrl      string `json:"slack_url" env:"SEMAPHORE_SLACK_URL"`
+	// SlackUrls are additional Slack webhooks, alerts are sent to all of them
+	SlackUrls []string `json:"slack_urls" env:"SEMAPHORE_SLACK_URLS"`
+
+	DiscordAlert bool   `json:"discord_alert" env:"SEMAPHORE_DISCORD_ALERT"`
+	DiscordUrl   string `json:"discord_url" env:"SEMAPHORE_DISCORD_URL"`
+
+	MsTeamsAlert bool   `json:"ms_teams_alert" env:"SEMAPHORE_MS_TEAMS_ALERT"`
+	MsTeamsUrl   string `json:"ms_teams_url" env:"SEMAPHORE_MS_TEAMS_URL"`
+
+	// WebhookAlert posts JSON payload of finished task to WebhookUrl and WebhookUrls.
+	// WebhookHeaders are added to the request, in env they are set
+	// as comma separated list, e.g. `Authorization=Bearer xxx,X-Source=semaphore`.
+	WebhookAlert bool   `json:"webhook_alert" env:"SEMAPHORE_WEBHOOK_ALERT"`
+	WebhookUrl   string `json:"webhook_url" env:"SEMAPHORE_WEBHOOK_URL"`
+	// WebhookUrls are additional webhooks, alerts are sent to all of them
+	WebhookUrls    []string          `json:"webhook_urls" env:"SEMAPHORE_WEBHOOK_URLS"`
+	WebhookHeaders map[string]string `json:"webhook_headers" env:"SEMAPHORE_WEBHOOK_HEADERS"`
 
 	// oidc settings
 	OidcProviders map[string]OidcProvider `json:"oidc_providers"`
 
-	// task concurrency
+	// OidcAccountLinking defines how OIDC identity is mapped to an existing account:
+	// "email" - by email, "username" - by username claim if email of the account matches,
+	// "none" - by email, but only to accounts previously created by SSO.
+	OidcAccountLinking string `json:"oidc_account_linking" default:"none" rule:"^(|email|username|none)$" env:"SEMAPHORE_OIDC_ACCOUNT_LINKING"`
+
+	// OidcJWKSCacheTTLSeconds is how long OIDC provider metadata and signing keys (JWKS)
+	// are cached. Keys rotated by provider are picked up after this time. 0 disables caching.
+	OidcJWKSCacheTTLSeconds int `json:"oidc_jwks_cache_ttl_seconds" default:"3600" rule:"^[0-9]{1,7}$" env:"SEMAPHORE_OIDC_JWKS_CACHE_TTL_SECONDS"`
+
+	// MaxConcurrentAuth limits number of simultaneous LDAP/OIDC authentications.
+	// 0 means unlimited.
+	MaxConcurrentAuth int `json:"max_concurrent_auth" default:"100" rule:"^[0-9]{1,6}$" env:"SEMAPHORE_MAX_CONCURRENT_AUTH"`
+
+	// task concurrency, 0 means unlimited
 	MaxParallelTasks int `json:"max_parallel_tasks" default:"10" rule:"^[0-9]{1,10}$" env:"SEMAPHORE_MAX_PARALLEL_TASKS"`
+	// TaskOverflowPolicy defines what happens with new tasks when MaxParallelTasks is reached:
+	// "queue" - task waits in the queue, "reject" - task is not created.
+	TaskOverflowPolicy string `json:"task_overflow_policy" default:"queue" rule:"^(|queue|reject)$" env:"SEMAPHORE_TASK_OVERFLOW_POLICY"`
+
+	// MaxTaskHistoryPerTemplate is number of the latest tasks of each template
+	// kept in the database. 0 means unlimited.
+	MaxTaskHistoryPerTemplate int `json:"max_task_history_per_template" rule:"^[0-9]{1,9}$" env:"SEMAPHORE_MAX_TASK_HISTORY_PER_TEMPLATE"`
+
+	// AnsibleExecutionEnvironment is a container engine ("podman" or "docker")
+	// used by ansible-navigator to run playbooks in AnsibleRunnerImage.
+	// Empty value runs ansible-playbook installed on the host.
+	AnsibleExecutionEnvironment string `json:"ansible_execution_environment" rule:"^(|podman|docker)$" env:"SEMAPHORE_ANSIBLE_EXECUTION_ENVIRONMENT"`
+	AnsibleRunnerImage          string `json:"ansible_runner_image" env:"SEMAPHORE_ANSIBLE_RUNNER_IMAGE"`
+
+	// AnsibleModuleDenylist forbids using of listed modules in playbooks,
+	// e.g. `shell,command`. Short names also deny ansible.builtin and ansible.legacy FQCNs.
+	AnsibleModuleDenylist []string `json:"ansible_module_denylist" env:"SEMAPHORE_ANSIBLE_MODULE_DENYLIST"`
 
 	RunnerRegistrationToken string `json:"runner_registration_token" env:"SEMAPHORE_RUNNER_REGISTRATION_TOKEN"`
 
@@ -179,537 +400,3964 @@ type ConfigType struct {
 	PasswordLoginDisable     bool `json:"password_login_disable" env:"SEMAPHORE_PASSWORD_LOGIN_DISABLED"`
 	NonAdminCanCreateProject bool `json:"non_admin_can_create_project" env:"SEMAPHORE_NON_ADMIN_CAN_CREATE_PROJECT"`
 
+	// AllowedEmailDomains restricts users auto-provisioned from LDAP/OIDC
+	// to the listed email domains. Empty list allows all domains.
+	AllowedEmailDomains []string `json:"allowed_email_domains" env:"SEMAPHORE_ALLOWED_EMAIL_DOMAINS"`
+
+	// LoginBannerTitle and LoginBannerText are shown on the login page,
+	// e.g. for legal notices.
+	LoginBannerTitle string `json:"login_banner_title" env:"SEMAPHORE_LOGIN_BANNER_TITLE"`
+	LoginBannerText  string `json:"login_banner_text" env:"SEMAPHORE_LOGIN_BANNER_TEXT"`
+
 	UseRemoteRunner bool `json:"use_remote_runner" env:"SEMAPHORE_USE_REMOTE_RUNNER"`
 
+	// OfflineMode disables requests to external services which are not
+	// configured explicitly, e.g. update check.
+	OfflineMode bool `json:"offline_mode" env:"SEMAPHORE_OFFLINE_MODE"`
+
+	// WatchConfig reloads config when the config file changes on disk
+	WatchConfig bool `json:"watch_config" env:"SEMAPHORE_WATCH_CONFIG"`
+
+	// IgnoreUnknownEnv disables warnings about unknown SEMAPHORE_* environment variables
+	IgnoreUnknownEnv bool `json:"ignore_unknown_env" env:"SEMAPHORE_IGNORE_UNKNOWN_ENV"`
+
+	// EnvOverrideDenylist lists field paths (e.g. `PasswordLoginDisable`, `MySQL.Password`)
+	// which can't be overridden by environment variables.
+	// It can be set only in the config file.
+	EnvOverrideDenylist []string `json:"env_override_denylist"`
+
+	// ConfigPrecedence defines which source wins if a field is set both in the config file
+	// and in the environment: `env` (default) or `file`.
+	ConfigPrecedence string `json:"config_precedence" default:"env" rule:"^(|env|file)$" env:"SEMAPHORE_CONFIG_PRECEDENCE"`
+
 	Runner RunnerSettings `json:"runner"`
 
 	BillingEnabled bool `json:"billing_enabled"`
 }
 
-// Config exposes the application configuration storage for use in the application
-var Config *ConfigType
+// EmailConfig groups settings required by the mailer
+type EmailConfig struct {
+	Alert     bool
+	Sender    string
+	Host      string
+	Port      string
+	Username  string
+	Password  string
+	Secure    bool
+	PoolSize  int
+	KeepAlive time.Duration
+}
 
-// ToJSON returns a JSON string of the config
-func (conf *ConfigType) ToJSON() ([]byte, error) {
-	return json.MarshalIndent(&conf, " ", "\t")
+// IsEnabled returns true if email alerting is turned on
+func (c EmailConfig) IsEnabled() bool {
+	return c.Alert
 }
 
-// ConfigInit reads in cli flags, and switches actions appropriately on them
-func ConfigInit(configPath string) {
-	fmt.Println("Loading config")
-	loadConfigFile(configPath)
-	loadConfigEnvironment()
-	loadConfigDefaults()
+// Validate checks that enabled email alerting has all required settings
+func (c EmailConfig) Validate() error {
+	if !c.Alert {
+		return nil
+	}
+	if c.Host == "" {
+		return errors.New("email alerting is enabled but email_host is not set")
+	}
+	if !emailAddressRegex.MatchString(c.Sender) {
+		return fmt.Errorf("email alerting is enabled but email_sender '%v' is not valid email address", c.Sender)
+	}
+	return nil
+}
 
-	fmt.Println("Validating config")
-	validateConfig()
+// validateLdap checks that enabled LDAP authentication has all required settings
+func validateLdap(conf *ConfigType) error {
+	if !conf.LdapEnable {
+		return nil
+	}
 
-	var encryption []byte
+	var missing []string
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"LdapServer", strings.Join(conf.GetLdapServers(), ",")},
+		{"LdapBindDN", conf.LdapBindDN},
+		{"LdapSearchDN", conf.LdapSearchDN},
+		{"LdapSearchFilter", conf.LdapSearchFilter},
+		{"LdapMappings.UID", conf.LdapMappings.UID},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
 
-	hash, _ := base64.StdEncoding.DecodeString(Config.CookieHash)
-	if len(Config.CookieEncryption) > 0 {
-		encryption, _ = base64.StdEncoding.DecodeString(Config.CookieEncryption)
+	if len(missing) > 0 {
+		return fmt.Errorf("LDAP is enabled but required fields are not set: %v", strings.Join(missing, ", "))
 	}
 
-	Cookie = securecookie.New(hash, encryption)
-	WebHostURL, _ = url.Parse(Config.WebHost)
-	if len(WebHostURL.String()) == 0 {
-		WebHostURL = nil
+	for _, server := range conf.LdapServers {
+		host, port, err := net.SplitHostPort(server)
+		if _, portErr := strconv.Atoi(port); err != nil || portErr != nil || host == "" {
+			return fmt.Errorf("value of field 'LdapServers' is not valid: %v (must be host:port)", server)
+		}
 	}
-}
 
-func loadConfigFile(configPath string) {
-	if configPath == "" {
-		configPath = os.Getenv("SEMAPHORE_CONFIG_PATH")
+	if conf.LdapNeedTLS && conf.LdapUseStartTLS {
+		return errors.New("value of field 'LdapUseStartTLS' is not valid: LdapNeedTLS (LDAPS) and LdapUseStartTLS can't be enabled together")
 	}
 
-	//If the configPath option has been set try to load and decode it
-	//var usedPath string
+	return nil
+}
 
-	if configPath == "" {
-		cwd, err := os.Getwd()
-		exitOnConfigFileError(err)
-		paths := []string{
-			path.Join(cwd, "config.json"),
-			"/usr/local/etc/semaphore/config.json",
+// LdapRoles are project roles which can be assigned by LdapGroupMappings
+// ordered from the most privileged. They must match db.ProjectUserRole values.
+var LdapRoles = []string{"owner", "manager", "task_runner", "guest"}
+
+func validateLdapGroupMappings(conf *ConfigType) error {
+	for group, role := range conf.LdapGroupMappings {
+		if strings.TrimSpace(group) == "" {
+			return errors.New("value of field 'LdapGroupMappings' is not valid: group DN is empty")
 		}
-		for _, p := range paths {
-			_, err = os.Stat(p)
-			if err != nil {
-				continue
-			}
-			var file *os.File
-			file, err = os.Open(p)
-			if err != nil {
-				continue
-			}
-			decodeConfig(file)
-			break
+		if !containsString(LdapRoles, role) {
+			return fmt.Errorf("value of field 'LdapGroupMappings' is not valid: unknown role '%v' for group '%v' (must be one of %v)",
+				role, group, strings.Join(LdapRoles, ", "))
 		}
-		exitOnConfigFileError(err)
-	} else {
-		p := configPath
-		file, err := os.Open(p)
-		exitOnConfigFileError(err)
-		decodeConfig(file)
 	}
+	return nil
 }
 
-func loadDefaultsToObject(obj interface{}) error {
-	var t = reflect.TypeOf(obj)
-	var v = reflect.ValueOf(obj)
-
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
-		v = reflect.Indirect(v)
+// GetLdapGroupRole returns the most privileged role mapped to any of the groups
+// or empty string if none of the groups is mapped. Group DNs are compared case-insensitively.
+func (conf *ConfigType) GetLdapGroupRole(groups []string) string {
+	roles := make(map[string]bool)
+	for mappedGroup, role := range conf.LdapGroupMappings {
+		for _, group := range groups {
+			if strings.EqualFold(strings.TrimSpace(group), strings.TrimSpace(mappedGroup)) {
+				roles[role] = true
+			}
+		}
 	}
 
-	for i := 0; i < t.NumField(); i++ {
-		fieldInfo := t.Field(i)
-		fieldValue := v.Field(i)
-
-		if !fieldValue.IsZero() && fieldInfo.Type.Kind() != reflect.Struct && fieldInfo.Type.Kind() != reflect.Map {
-			continue
+	for _, role := range LdapRoles {
+		if roles[role] {
+			return role
 		}
+	}
 
-		if fieldInfo.Type.Kind() == reflect.Struct {
-			err := loadDefaultsToObject(fieldValue.Addr().Interface())
-			if err != nil {
-				return err
-			}
-			continue
-		} else if fieldInfo.Type.Kind() == reflect.Map {
-			for _, key := range fieldValue.MapKeys() {
-				val := fieldValue.MapIndex(key)
-
-				if val.Type().Kind() != reflect.Struct {
-					continue
-				}
+	return ""
+}
 
-				newVal := reflect.New(val.Type())
-				pointerValue := newVal.Elem()
-				pointerValue.Set(val)
+// emailAddressRegex only checks basic shape of address, `semaphore@localhost` is valid
+var emailAddressRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
 
-				err := loadDefaultsToObject(newVal.Interface())
-				if err != nil {
-					return err
-				}
+func validateEmail(conf *ConfigType) error {
+	return conf.GetEmailConfig().Validate()
+}
 
-				fieldValue.SetMapIndex(key, newVal.Elem())
-			}
-			continue
-		}
+// SlackConfig groups settings required for Slack alerting
+type SlackConfig struct {
+	Alert bool
+	Urls  []string
+}
 
-		defaultVar := fieldInfo.Tag.Get("default")
-		if defaultVar == "" {
-			continue
-		}
+// IsEnabled returns true if Slack alerting is turned on
+func (c SlackConfig) IsEnabled() bool {
+	return c.Alert
+}
 
-		setConfigValue(fieldValue, defaultVar)
+// Validate checks that enabled Slack alerting has all required settings
+func (c SlackConfig) Validate() error {
+	if c.Alert && len(c.Urls) == 0 {
+		return errors.New("slack alerting is enabled but neither slack_url nor slack_urls is set")
 	}
-
 	return nil
 }
 
-func loadConfigDefaults() {
+// DiscordConfig groups settings required for Discord alerting
+type DiscordConfig struct {
+	Alert bool
+	Url   string
+}
 
-	err := loadDefaultsToObject(Config)
-	if err != nil {
-		panic(err)
+// IsEnabled returns true if Discord alerting is turned on
+func (c DiscordConfig) IsEnabled() bool {
+	return c.Alert
+}
+
+// Validate checks that enabled Discord alerting has all required settings
+func (c DiscordConfig) Validate() error {
+	if c.Alert && c.Url == "" {
+		return errors.New("discord alerting is enabled but discord_url is not set")
 	}
+	return nil
 }
 
-func castStringToInt(value string) int {
+// MsTeamsConfig groups settings required for Microsoft Teams alerting
+type MsTeamsConfig struct {
+	Alert bool
+	Url   string
+}
 
-	valueInt, err := strconv.Atoi(value)
-	if err != nil {
-		panic(err)
+// IsEnabled returns true if Microsoft Teams alerting is turned on
+func (c MsTeamsConfig) IsEnabled() bool {
+	return c.Alert
+}
+
+// Validate checks that enabled Microsoft Teams alerting has all required settings
+func (c MsTeamsConfig) Validate() error {
+	if c.Alert && c.Url == "" {
+		return errors.New("microsoft teams alerting is enabled but ms_teams_url is not set")
 	}
-	return valueInt
+	return nil
+}
 
+// WebhookConfig groups settings required for generic webhook alerting
+type WebhookConfig struct {
+	Alert   bool
+	Urls    []string
+	Headers map[string]string
 }
 
-func castStringToBool(value string) bool {
+// IsEnabled returns true if webhook alerting is turned on
+func (c WebhookConfig) IsEnabled() bool {
+	return c.Alert
+}
 
-	var valueBool bool
-	if value == "1" || strings.ToLower(value) == "true" || strings.ToLower(value) == "yes" {
-		valueBool = true
-	} else {
-		valueBool = false
+// Validate checks that enabled webhook alerting has all required settings
+func (c WebhookConfig) Validate() error {
+	if c.Alert && len(c.Urls) == 0 {
+		return errors.New("webhook alerting is enabled but neither webhook_url nor webhook_urls is set")
 	}
-	return valueBool
+	return nil
+}
 
+// TelegramConfig groups settings required for Telegram alerting
+type TelegramConfig struct {
+	Alert bool
+	Chat  string
+	Token string
 }
 
-func setConfigValue(attribute reflect.Value, value interface{}) {
+// IsEnabled returns true if Telegram alerting is turned on
+func (c TelegramConfig) IsEnabled() bool {
+	return c.Alert
+}
 
-	if attribute.IsValid() {
-		switch attribute.Kind() {
-		case reflect.Int:
-			if reflect.ValueOf(value).Kind() != reflect.Int {
-				value = castStringToInt(fmt.Sprintf("%v", reflect.ValueOf(value)))
-			}
-		case reflect.Bool:
-			if reflect.ValueOf(value).Kind() != reflect.Bool {
-				value = castStringToBool(fmt.Sprintf("%v", reflect.ValueOf(value)))
-			}
-		}
-		attribute.Set(reflect.ValueOf(value))
-	} else {
-		panic(fmt.Errorf("got non-existent config attribute"))
+// Validate checks that enabled Telegram alerting has all required settings.
+// Chat can be empty because it can be overridden by template.
+func (c TelegramConfig) Validate() error {
+	if !c.Alert {
+		return nil
+	}
+	if c.Token == "" {
+		return errors.New("telegram alerting is enabled but telegram_token is not set")
+	}
+	// token is not included into the message because it is a secret
+	if !telegramTokenRegex.MatchString(c.Token) {
+		return errors.New("value of field 'TelegramToken' is not valid: must have format <bot_id>:<secret>")
+	}
+	if c.Chat != "" && !telegramChatRegex.MatchString(c.Chat) {
+		return fmt.Errorf("value of field 'TelegramChat' is not valid: %v is neither numeric chat ID nor @channelname", c.Chat)
 	}
+	return nil
+}
 
+var telegramTokenRegex = regexp.MustCompile(`^[0-9]+:[a-zA-Z0-9_-]+$`)
+var telegramChatRegex = regexp.MustCompile(`^(-?[0-9]+|@[a-zA-Z][a-zA-Z0-9_]{4,31})$`)
+
+func validateTelegram(conf *ConfigType) error {
+	_, err := conf.GetTelegramConfig()
+	return err
 }
 
-func getConfigValue(path string) string {
+// AlertChannelConfig is implemented by settings of each alerting channel
+type AlertChannelConfig interface {
+	IsEnabled() bool
+	Validate() error
+}
 
-	attribute := reflect.ValueOf(Config)
-	nested_path := strings.Split(path, ".")
+// Config exposes the application configuration storage for use in the application.
+// Code which can run concurrently with ReloadConfig should use GetConfig instead.
+var Config *ConfigType
 
-	for i, nested := range nested_path {
-		attribute = reflect.Indirect(attribute).FieldByName(nested)
-		lastDepth := len(nested_path) == i+1
-		if !lastDepth && attribute.Kind() != reflect.Struct || lastDepth && attribute.Kind() == reflect.Invalid {
-			panic(fmt.Errorf("got non-existent config attribute '%v'", path))
-		}
-	}
+// configFilePath is a path of the config file loaded by ConfigInit
+var configFilePath string
 
-	return fmt.Sprintf("%v", attribute)
+// ToJSON returns a JSON string of the config indented by two spaces.
+// Output is stable, so it can be stored in version control.
+func (conf *ConfigType) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(conf, "", "  ")
 }
 
-func validate(value interface{}) error {
-	var t = reflect.TypeOf(value)
-	var v = reflect.ValueOf(value)
-
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
-		v = reflect.Indirect(v)
+// ToYAML returns a YAML string of the config. Keys are the same as in JSON
+// and are sorted, so output is stable.
+func (conf *ConfigType) ToYAML() ([]byte, error) {
+	bytes, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < t.NumField(); i++ {
-		fieldType := t.Field(i)
-		fieldValue := v.Field(i)
+	decoder := json.NewDecoder(strings.NewReader(string(bytes)))
+	decoder.UseNumber()
 
-		rule := fieldType.Tag.Get("rule")
-		if rule == "" {
-			continue
-		}
+	var data interface{}
+	if err = decoder.Decode(&data); err != nil {
+		return nil, err
+	}
 
-		var strVal string
+	return yaml.Marshal(jsonNumbersToYAML(data))
+}
 
-		if fieldType.Type.Kind() == reflect.Int {
-			strVal = strconv.FormatInt(fieldValue.Int(), 10)
-		} else if fieldType.Type.Kind() == reflect.Uint {
-			strVal = strconv.FormatUint(fieldValue.Uint(), 10)
-		} else {
-			strVal = fieldValue.String()
+// jsonNumbersToYAML replaces json.Number with int64 or float64,
+// otherwise numbers are written to YAML as strings.
+func jsonNumbersToYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonNumbersToYAML(item)
 		}
-
-		match, _ := regexp.MatchString(rule, strVal)
-
-		if match {
-			continue
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonNumbersToYAML(item)
 		}
-
-		fieldName := strings.ToLower(fieldType.Name)
-
-		if strings.Contains(fieldName, "password") || strings.Contains(fieldName, "secret") || strings.Contains(fieldName, "key") {
-			strVal = "***"
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
 		}
-
-		return fmt.Errorf(
-			"value of field '%v' is not valid: %v (Must match regex: '%v')",
-			fieldType.Name, strVal, rule,
-		)
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
 	}
+}
 
-	return nil
+// ToJSONCompact returns a JSON string of the config without whitespaces
+func (conf *ConfigType) ToJSONCompact() ([]byte, error) {
+	return json.Marshal(conf)
 }
 
-func validateConfig() {
+// secretConfigFields are sensitive fields which are not caught by isSecretConfigField heuristic
+var secretConfigFields = []string{
+	"CookieHash",
+	"CookieEncryption",
+	"AccessKeyEncryption",
+	"AccessKeyEncryptionOld",
+	"CookieVerifyKeys",
+	"SlackUrl",
+	"SlackUrls",
+	"DiscordUrl",
+	"MsTeamsUrl",
+	"WebhookUrl",
+	"WebhookUrls",
+	"WebhookHeaders",
+}
+
+// isSecretConfigField returns true if value of the field (or map key) with the name must not be disclosed
+func isSecretConfigField(name string) bool {
+	for _, secret := range secretConfigFields {
+		if name == secret {
+			return true
+		}
+	}
 
-	err := validate(Config)
+	name = strings.ToLower(name)
 
-	if err != nil {
-		panic(err)
+	return strings.Contains(name, "password") ||
+		strings.Contains(name, "secret") ||
+		strings.Contains(name, "token")
+}
+
+// isSecretConfigPath returns true if any element of the field path is secret,
+// so all values of secret map, like WebhookHeaders, are secret too.
+func isSecretConfigPath(path string) bool {
+	for _, name := range strings.Split(path, ".") {
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+		if isSecretConfigField(name) {
+			return true
+		}
 	}
+	return false
+}
+
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "***"
+}
+
+// ToRedactedJSON returns a JSON string of the config with all sensitive values masked
+func (conf *ConfigType) ToRedactedJSON() ([]byte, error) {
+	bytes, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var redacted ConfigType
+	if err = json.Unmarshal(bytes, &redacted); err != nil {
+		return nil, err
+	}
+
+	walkStringFields(&redacted, func(path string, get func() string, set func(string)) {
+		if isSecretConfigPath(path) {
+			set(redactValue(get()))
+		}
+	})
+
+	return redacted.ToJSON()
+}
+
+// String returns the config as JSON with all sensitive values masked,
+// so printing of the config doesn't disclose secrets.
+func (conf *ConfigType) String() string {
+	if conf == nil {
+		return "<nil>"
+	}
+
+	bytes, err := conf.ToRedactedJSON()
+	if err != nil {
+		return fmt.Sprintf("<invalid config: %v>", err)
+	}
+
+	return string(bytes)
+}
+
+// DetectedEnvOverrides returns environment variables which override config values.
+// Values of sensitive fields are masked.
+func DetectedEnvOverrides() map[string]string {
+	overrides := make(map[string]string)
+	detectEnvOverrides(reflect.TypeOf(ConfigType{}), overrides)
+	return overrides
+}
+
+// knownEnvVars are environment variables which are not mapped to config fields
+var knownEnvVars = []string{
+	"SEMAPHORE_CONFIG_PATH",
+	"SEMAPHORE_CONFIG_DIR",
+	"SEMAPHORE_CONFIG_FORMAT",
+	"SEMAPHORE_CONFIG_EXPAND_ENV",
+	"SEMAPHORE_CONFIG_ENV_STRICT",
+	"SEMAPHORE_CONFIG_TRACE",
+	"SEMAPHORE_DB_NAME",
+	"SEMAPHORE_DB_PORT",
+	"SEMAPHORE_DB_PATH",
+	"SEMAPHORE_LDAP_PASSWORD",
+	"SEMAPHORE_ADMIN",
+	"SEMAPHORE_ADMIN_NAME",
+	"SEMAPHORE_ADMIN_EMAIL",
+	"SEMAPHORE_ADMIN_PASSWORD",
+
+	// used by docker entrypoint script
+	"SEMAPHORE_DB_DIALECT_ID",
+	"SEMAPHORE_LDAP_ACTIVATED",
+	"SEMAPHORE_LDAP_HOST",
+	"SEMAPHORE_LDAP_PORT",
+	"SEMAPHORE_LDAP_DN_BIND",
+	"SEMAPHORE_LDAP_DN_SEARCH",
+	"SEMAPHORE_LDAP_MAPPING_DN",
+	"SEMAPHORE_LDAP_MAPPING_EMAIL",
+	"SEMAPHORE_LDAP_MAPPING_FULLNAME",
+	"SEMAPHORE_LDAP_MAPPING_USERNAME",
+	"SEMAPHORE_VERSION",
+	"SEMAPHORE_ARCH",
+}
+
+func collectConfigEnvVars(t reflect.Type, envVars map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			collectConfigEnvVars(field.Type, envVars)
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Map && field.Type.Elem().Kind() == reflect.Struct {
+			collectConfigEnvVars(field.Type.Elem(), envVars)
+			continue
+		}
+
+		if envVar := field.Tag.Get("env"); envVar != "" {
+			envVars[envVar] = true
+			if isSecretConfigField(field.Name) {
+				envVars[envVar+"_FILE"] = true
+			}
+		}
+	}
+}
+
+// allKnownEnvVars returns set of environment variables used by Semaphore
+func allKnownEnvVars() map[string]bool {
+	known := make(map[string]bool)
+	collectConfigEnvVars(reflect.TypeOf(ConfigType{}), known)
+	for _, envVar := range knownEnvVars {
+		known[envVar] = true
+	}
+	return known
+}
+
+// UnknownEnvVars returns SEMAPHORE_* environment variables which are not
+// used by Semaphore. They are likely typos, e.g. SEMAPHORE_DB_HOSTS.
+func UnknownEnvVars() []string {
+	known := allKnownEnvVars()
+
+	var unknown []string
+
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.HasPrefix(name, "SEMAPHORE_") || known[name] {
+			continue
+		}
+		unknown = append(unknown, name)
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// suggestEnvVar returns the most similar known variable or empty string
+// if there are no similar variables.
+func suggestEnvVar(name string) string {
+	suggestion := ""
+	bestDistance := 3 // suggest only names which differ by 1-2 characters
+
+	for envVar := range allKnownEnvVars() {
+		distance := levenshteinDistance(name, envVar)
+		if distance < bestDistance || (distance == bestDistance && envVar < suggestion) {
+			suggestion = envVar
+			bestDistance = distance
+		}
+	}
+
+	return suggestion
+}
+
+func levenshteinDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+func warnUnknownEnvVars() {
+	if Config.IgnoreUnknownEnv {
+		return
+	}
+
+	for _, name := range UnknownEnvVars() {
+		if suggestion := suggestEnvVar(name); suggestion != "" {
+			log.Warnf("Unknown environment variable %s, did you mean %s?", name, suggestion)
+		} else {
+			log.Warnf("Unknown environment variable %s", name)
+		}
+	}
+}
+
+func detectEnvOverrides(t reflect.Type, overrides map[string]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			detectEnvOverrides(field.Type, overrides)
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Map && field.Type.Elem().Kind() == reflect.Struct {
+			detectEnvOverrides(field.Type.Elem(), overrides)
+			continue
+		}
+
+		envVar := field.Tag.Get("env")
+		if envVar == "" {
+			continue
+		}
+
+		value, exists := os.LookupEnv(envVar)
+		if !exists {
+			continue
+		}
+
+		if isSecretConfigField(field.Name) {
+			value = redactValue(value)
+		}
+
+		overrides[envVar] = value
+	}
+}
+
+// SanitizedReport returns information required to triage bug reports.
+// It contains no secrets and is safe to publish.
+func (conf *ConfigType) SanitizedReport() string {
+	var report strings.Builder
+
+	fmt.Fprintf(&report, "Semaphore: %v\n", Version)
+	fmt.Fprintf(&report, "Config file: %v\n", ConfigFilePath())
+
+	if dbConfig, err := conf.GetDBConfig(); err == nil {
+		fmt.Fprintf(&report, "Database: %v %v@%v %v\n", dbConfig.Dialect, dbConfig.GetUsername(), dbConfig.GetHostname(), dbConfig.GetDbName())
+	} else {
+		fmt.Fprintf(&report, "Database: %v\n", err)
+	}
+
+	ansibleVersion := strings.SplitN(AnsibleVersion(), "\n", 2)[0]
+	if ansibleVersion == "" {
+		ansibleVersion = "not found"
+	}
+	fmt.Fprintf(&report, "Ansible: %v\n", ansibleVersion)
+
+	overrides := DetectedEnvOverrides()
+	envVars := make([]string, 0, len(overrides))
+	for envVar := range overrides {
+		envVars = append(envVars, envVar)
+	}
+	sort.Strings(envVars)
+
+	report.WriteString("Environment overrides:\n")
+	for _, envVar := range envVars {
+		fmt.Fprintf(&report, "  %v=%v\n", envVar, overrides[envVar])
+	}
+
+	report.WriteString("Config:\n")
+	bytes, err := conf.ToRedactedJSON()
+	if err != nil {
+		fmt.Fprintf(&report, "%v\n", err)
+	} else {
+		report.Write(bytes)
+		report.WriteString("\n")
+	}
+
+	return report.String()
+}
+
+// AdminBootstrap is a one-time admin account read from environment.
+// It is used only to create the first user and is never kept in Config.
+type AdminBootstrap struct {
+	Login    string
+	Name     string
+	Email    string
+	Password string
+}
+
+const minAdminBootstrapPasswordLength = 8
+
+// GetAdminBootstrap reads admin account from SEMAPHORE_ADMIN, SEMAPHORE_ADMIN_NAME,
+// SEMAPHORE_ADMIN_EMAIL and SEMAPHORE_ADMIN_PASSWORD. Returns nil if
+// SEMAPHORE_ADMIN_PASSWORD is not set.
+func GetAdminBootstrap() (*AdminBootstrap, error) {
+	password := os.Getenv("SEMAPHORE_ADMIN_PASSWORD")
+	if password == "" {
+		return nil, nil
+	}
+
+	admin := &AdminBootstrap{
+		Login:    os.Getenv("SEMAPHORE_ADMIN"),
+		Name:     os.Getenv("SEMAPHORE_ADMIN_NAME"),
+		Email:    os.Getenv("SEMAPHORE_ADMIN_EMAIL"),
+		Password: password,
+	}
+
+	if admin.Login == "" {
+		admin.Login = "admin"
+	}
+
+	if admin.Name == "" {
+		admin.Name = admin.Login
+	}
+
+	if admin.Email == "" {
+		return nil, errors.New("SEMAPHORE_ADMIN_EMAIL is required when SEMAPHORE_ADMIN_PASSWORD is set")
+	}
+
+	if len(admin.Password) < minAdminBootstrapPasswordLength {
+		return nil, fmt.Errorf("SEMAPHORE_ADMIN_PASSWORD must be at least %d characters long", minAdminBootstrapPasswordLength)
+	}
+
+	return admin, nil
+}
+
+// ConfigInit reads in cli flags, and switches actions appropriately on them
+func ConfigInit(configPath string) {
+	fmt.Println("Loading config")
+	tracer := newConfigSourceTracer()
+	loadConfigFile(configPath)
+	tracer.record(Config, ConfigSourceFile)
+	loadConfigSecretsFile()
+	tracer.record(Config, ConfigSourceSecretsFile)
+	loadConfigDirectory()
+	tracer.record(Config, ConfigSourceDirectory)
+	loadConfigEnvironment()
+	tracer.record(Config, ConfigSourceEnv)
+	loadConfigVault()
+	tracer.record(Config, ConfigSourceVault)
+	loadConfigAWSSecrets()
+	tracer.record(Config, ConfigSourceAWSSecrets)
+	loadConfigDefaults()
+	tracer.record(Config, ConfigSourceDefault)
+	tracer.apply()
+	warnUnknownEnvVars()
+
+	fmt.Println("Validating config")
+	validateConfig()
+	warnUnlimitedParallelTasks()
+	warnUnencryptedAccessKeys(Config)
+
+	SetConfig(Config)
+
+	if Config.WatchConfig {
+		if err := watchConfigFile(configFilePath, reloadConfigFile); err != nil {
+			LogWarning(err)
+		}
+	}
+}
+
+// applyConfig initializes runtime objects which depend on Config
+func applyConfig() {
+	CookieCodecs = Config.GetCookieCodecs()
+	Cookie = CookieCodecs[0].(*securecookie.SecureCookie)
+	WebHostURL, _ = url.Parse(Config.WebHost)
+	if len(WebHostURL.String()) == 0 {
+		WebHostURL = nil
+	}
+}
+
+// loadConfigObject loads config from the file, config directory and environment
+// like ConfigInit does, but returns error instead of exiting.
+func loadConfigObject(configPath string) (conf *ConfigType, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			conf = nil
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	format, err := getConfigFormat(configPath)
+	if err != nil {
+		return
+	}
+
+	file, err := os.Open(configPath)
+	if err != nil {
+		return
+	}
+	defer file.Close() //nolint:errcheck
+
+	conf = new(ConfigType)
+
+	if err = decodeConfigObject(file, format, conf); err != nil {
+		return
+	}
+
+	if err = loadSecretsFileToObject(conf); err != nil {
+		return
+	}
+
+	if dir := os.Getenv("SEMAPHORE_CONFIG_DIR"); dir != "" {
+		if err = loadDirectoryToObject(conf, dir); err != nil {
+			return
+		}
+	}
+
+	if err = loadConfigEnvironmentToObject(conf); err != nil {
+		return
+	}
+
+	if err = loadVaultSecretsToObject(conf); err != nil {
+		return
+	}
+
+	if err = resolveAWSSecretsToObject(conf); err != nil {
+		return
+	}
+
+	if err = loadConfigDefaultsToObject(conf); err != nil {
+		return
+	}
+
+	err = validateConfigObject(conf)
+	return
+}
+
+// configLock guards replacing of Config at runtime
+var configLock sync.RWMutex
+
+// configUpdateLock serializes updates which build new config from the current one,
+// so concurrent updates can't overwrite each other
+var configUpdateLock sync.Mutex
+
+// GetConfig returns current config
+func GetConfig() *ConfigType {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return Config
+}
+
+// SetConfig replaces Config and runtime objects which depend on it
+func SetConfig(conf *ConfigType) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	Config = conf
+	applyConfig()
+}
+
+// ReloadConfig loads config from the file, config directory and environment
+// and replaces the current config. The file loaded by ConfigInit is used
+// if configPath is empty. Current config is kept if the new one is not valid
+// or changes fields which require restart.
+func ReloadConfig(configPath string) error {
+	if configPath == "" {
+		configPath = configFilePath
+	}
+
+	if configPath == "" {
+		return errors.New("config is not loaded from a file")
+	}
+
+	configUpdateLock.Lock()
+	defer configUpdateLock.Unlock()
+
+	conf, err := loadConfigObject(configPath)
+	if err != nil {
+		return err
+	}
+
+	if changed := changedImmutableConfigFields(GetConfig(), conf); len(changed) > 0 {
+		return fmt.Errorf("fields %v can't be changed without restart", strings.Join(changed, ", "))
+	}
+
+	SetConfig(conf)
+	return nil
+}
+
+// changedImmutableConfigFields returns names of immutableConfigFields
+// which have different values in the configs.
+func changedImmutableConfigFields(current *ConfigType, conf *ConfigType) (changed []string) {
+	if current == nil {
+		return
+	}
+
+	currentValue := reflect.ValueOf(current).Elem()
+	confValue := reflect.ValueOf(conf).Elem()
+
+	for _, name := range immutableConfigFields {
+		if !reflect.DeepEqual(currentValue.FieldByName(name).Interface(), confValue.FieldByName(name).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+
+	return
+}
+
+// reloadConfigFile reloads config from the previously loaded file.
+// Current config is kept if the new one is not valid.
+func reloadConfigFile() {
+	if err := ReloadConfig(configFilePath); err != nil {
+		log.Errorf("Config %s is not reloaded: %v", configFilePath, err)
+		return
+	}
+
+	log.Infof("Config %s reloaded", configFilePath)
+}
+
+// immutableConfigFields are fields which can't be changed at runtime
+// because they are used only on startup.
+var immutableConfigFields = []string{
+	"ConfigVersion",
+	"MySQL",
+	"BoltDb",
+	"Postgres",
+	"Dialect",
+	"Port",
+	"Interface",
+	"TmpPath",
+	"CookieHash",
+	"CookieEncryption",
+	"CookieVerifyKeys",
+	"AccessKeyEncryption",
+	"AccessKeyEncryptionOld",
+	"SecretsFile",
+	"EnvOverrideDenylist",
+	"ConfigPrecedence",
+	"Vault",
+	"RequireDbTLS",
+	"WatchConfig",
+}
+
+// configFieldName returns Go name of the top level field of the path
+func configFieldName(path string) string {
+	name := strings.Split(path, ".")[0]
+
+	t := reflect.TypeOf(ConfigType{})
+	for i := 0; i < t.NumField(); i++ {
+		jsonName := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if t.Field(i).Name == name || jsonName == name {
+			return t.Field(i).Name
+		}
+	}
+
+	return ""
+}
+
+// ApplyPatch sets values of the given field paths, e.g. `slack_alert` or
+// `email_sender`. Changes are applied only if all values are valid.
+func ApplyPatch(patch map[string]interface{}) []error {
+	var errs []error
+
+	configUpdateLock.Lock()
+	defer configUpdateLock.Unlock()
+
+	bytes, err := json.Marshal(GetConfig())
+	if err != nil {
+		return []error{err}
+	}
+
+	conf := new(ConfigType)
+	if err = json.Unmarshal(bytes, conf); err != nil {
+		return []error{err}
+	}
+
+	paths := make([]string, 0, len(patch))
+	for path := range patch {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err = patchConfigValue(conf, path, patch[path]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if errs = validateConfigErrors(conf); len(errs) > 0 {
+		return errs
+	}
+
+	SetConfig(conf)
+
+	return nil
+}
+
+func patchConfigValue(conf *ConfigType, path string, value interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("value of field '%v' is not valid: %v", path, r)
+		}
+	}()
+
+	name := configFieldName(path)
+	if name == "" {
+		return fmt.Errorf("field '%v' does not exist", path)
+	}
+
+	for _, immutable := range immutableConfigFields {
+		if name == immutable {
+			return fmt.Errorf("field '%v' can't be changed at runtime", path)
+		}
+	}
+
+	if err = setConfigValue(findConfigField(reflect.ValueOf(conf), path), value); err != nil {
+		err = fmt.Errorf("value of field '%v' is not valid: %v", path, err)
+	}
+	return
+}
+
+// configWatchDebounce is a delay after the last change of the config file before reload
+const configWatchDebounce = 500 * time.Millisecond
+
+// watchConfigFile calls onChange when the config file is changed.
+// Directory of the file is watched because editors and Kubernetes
+// replace files instead of writing to them.
+func watchConfigFile(configPath string, onChange func()) error {
+	if configPath == "" {
+		return errors.New("watch_config is enabled but config is not loaded from a file")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if err = watcher.Add(filepath.Dir(configPath)); err != nil {
+		watcher.Close() //nolint:errcheck
+		return err
+	}
+
+	go func() {
+		var timer *time.Timer
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Base(event.Name) != filepath.Base(configPath) {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(configWatchDebounce, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				LogWarning(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func loadConfigFile(configPath string) {
+	if configPath == "" {
+		configPath = os.Getenv("SEMAPHORE_CONFIG_PATH")
+	}
+
+	//If the configPath option has been set try to load and decode it
+	//var usedPath string
+
+	if configPath == "" {
+		cwd, err := os.Getwd()
+		exitOnConfigFileError(err)
+		paths := []string{
+			path.Join(cwd, "config.json"),
+			path.Join(cwd, "config.yaml"),
+			path.Join(cwd, "config.yml"),
+			"/usr/local/etc/semaphore/config.json",
+			"/usr/local/etc/semaphore/config.yaml",
+			"/usr/local/etc/semaphore/config.yml",
+		}
+		for _, p := range paths {
+			_, err = os.Stat(p)
+			if err != nil {
+				continue
+			}
+			var file *os.File
+			file, err = os.Open(p)
+			if err != nil {
+				continue
+			}
+			if err = decodeConfig(file, getConfigFormatOrExit(p)); err != nil {
+				exitOnConfigError(fmt.Sprintf("Could not decode configuration file %s: %v", p, err))
+			}
+			configFilePath = p
+			break
+		}
+		exitOnConfigFileError(err)
+	} else {
+		p := configPath
+		file, err := os.Open(p)
+		exitOnConfigFileError(err)
+		if err = decodeConfig(file, getConfigFormatOrExit(p)); err != nil {
+			exitOnConfigError(fmt.Sprintf("Could not decode configuration file %s: %v", p, err))
+		}
+		configFilePath = p
+	}
+}
+
+// ConfigFilePath returns path of the loaded config file
+func ConfigFilePath() string {
+	return configFilePath
+}
+
+const (
+	ConfigFormatJSON = "json"
+	ConfigFormatYAML = "yaml"
+	ConfigFormatTOML = "toml"
+)
+
+// configDecoders maps supported config formats to their decoders
+var configDecoders = map[string]func(file io.Reader, conf interface{}) error{
+	ConfigFormatJSON: func(file io.Reader, conf interface{}) error {
+		decoder := json.NewDecoder(file)
+		// keep numbers as is when config is decoded to map before migration
+		decoder.UseNumber()
+		return decoder.Decode(conf)
+	},
+	ConfigFormatYAML: decodeYAMLConfig,
+	"yml":            decodeYAMLConfig,
+	ConfigFormatTOML: decodeTOMLConfig,
+}
+
+// decodeYAMLConfig converts YAML to JSON before decoding,
+// so YAML config uses the same keys as JSON config.
+func decodeYAMLConfig(file io.Reader, conf interface{}) error {
+	var data interface{}
+	if err := yaml.NewDecoder(file).Decode(&data); err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(yamlToJSONValue(data))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bytes, conf)
+}
+
+// decodeTOMLConfig converts TOML to JSON before decoding,
+// so TOML config uses the same keys as JSON config.
+func decodeTOMLConfig(file io.Reader, conf interface{}) error {
+	var data map[string]interface{}
+	if _, err := toml.NewDecoder(file).Decode(&data); err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bytes, conf)
+}
+
+// CurrentConfigVersion is version of the config schema supported by this build
+const CurrentConfigVersion = 1
+
+// configMigration upgrades config to the version.
+// Renames map old dot separated JSON paths to the new ones.
+type configMigration struct {
+	version int
+	renames map[string]string
+}
+
+// configMigrations must be ordered by version. Version 1 is the first
+// versioned schema, unversioned configs are treated as version 0.
+var configMigrations = []configMigration{
+	{version: 1},
+}
+
+// decodeConfigObject decodes config of the format to conf
+// and migrates it from older config versions.
+func decodeConfigObject(file io.Reader, format string, conf interface{}) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]interface{}
+	if err = configDecoders[format](strings.NewReader(string(data)), &raw); err != nil {
+		if format == ConfigFormatJSON {
+			return describeJSONConfigError(data, err)
+		}
+		return err
+	}
+
+	if raw == nil {
+		raw = make(map[string]interface{})
+	}
+
+	changes, err := migrateConfig(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		log.Info("Config migration: " + change)
+	}
+
+	if isConfigEnvExpansionEnabled() {
+		if _, err = expandConfigEnv(raw, os.Getenv("SEMAPHORE_CONFIG_ENV_STRICT") != ""); err != nil {
+			return err
+		}
+	}
+
+	bytes, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bytes, conf)
+}
+
+// isConfigEnvExpansionEnabled returns true if references to environment variables
+// in the config file must be expanded. Expansion is opt-in by SEMAPHORE_CONFIG_EXPAND_ENV,
+// because it changes existing values which contain $.
+func isConfigEnvExpansionEnabled() bool {
+	enabled, err := castStringToBool(os.Getenv("SEMAPHORE_CONFIG_EXPAND_ENV"))
+	return err == nil && enabled
+}
+
+// configEnvReferenceRegex matches $$, ${VAR} and $VAR in string values of the config file
+var configEnvReferenceRegex = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expandConfigEnv replaces references to environment variables in string values
+// of the raw config. $$ is replaced by $. References to missing variables
+// are kept as is, or cause error if strict is true.
+func expandConfigEnv(value interface{}, strict bool) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var err error
+		expanded := configEnvReferenceRegex.ReplaceAllStringFunc(v, func(ref string) string {
+			if ref == "$$" {
+				return "$"
+			}
+
+			match := configEnvReferenceRegex.FindStringSubmatch(ref)
+			name := match[1] + match[2]
+
+			envValue, exists := os.LookupEnv(name)
+			if !exists {
+				if strict && err == nil {
+					err = fmt.Errorf("environment variable %v referenced in config is not set", name)
+				}
+				return ref
+			}
+			return envValue
+		})
+		return expanded, err
+	case map[string]interface{}:
+		for key, item := range v {
+			expanded, err := expandConfigEnv(item, strict)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = expanded
+		}
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := expandConfigEnv(item, strict)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+
+	return value, nil
+}
+
+// describeJSONConfigError adds line and column of the syntax error to err
+func describeJSONConfigError(data []byte, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		// offset of syntax error includes the invalid character
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		offset = int64(len(data))
+		err = errors.New("unexpected end of JSON input, the file may be truncated")
+	default:
+		return err
+	}
+
+	if offset < 0 {
+		offset = 0
+	} else if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := strings.Count(string(before), "\n") + 1
+	column := len(before) - strings.LastIndex(string(before), "\n")
+
+	return fmt.Errorf("line %d, column %d: %v", line, column, err)
+}
+
+// migrateConfig applies migrations newer than config_version of the raw config
+// and bumps the version. It returns descriptions of the applied changes.
+func migrateConfig(raw map[string]interface{}) (changes []string, err error) {
+	version := 0
+	if value, ok := raw["config_version"]; ok {
+		version, err = strconv.Atoi(fmt.Sprint(value))
+		if err != nil {
+			return nil, fmt.Errorf("value of field 'ConfigVersion' is not valid: %v", value)
+		}
+	}
+
+	latest := 0
+	for _, migration := range configMigrations {
+		latest = migration.version
+
+		if migration.version <= version {
+			continue
+		}
+
+		// apply renames in stable order
+		oldPaths := make([]string, 0, len(migration.renames))
+		for oldPath := range migration.renames {
+			oldPaths = append(oldPaths, oldPath)
+		}
+		sort.Strings(oldPaths)
+
+		for _, oldPath := range oldPaths {
+			newPath := migration.renames[oldPath]
+			switch moveConfigValue(raw, oldPath, newPath) {
+			case configValueMoved:
+				changes = append(changes, fmt.Sprintf("'%s' renamed to '%s'", oldPath, newPath))
+			case configValueConflict:
+				changes = append(changes, fmt.Sprintf("'%s' ignored because '%s' is already set", oldPath, newPath))
+			}
+		}
+	}
+
+	if version > latest {
+		log.Warnf("Config version %d is newer than supported version %d", version, latest)
+		return
+	}
+
+	if version != latest {
+		raw["config_version"] = latest
+		changes = append(changes, fmt.Sprintf("version updated from %d to %d", version, latest))
+	}
+
+	return
+}
+
+const (
+	configValueMissing = iota
+	configValueMoved
+	configValueConflict
+)
+
+// moveConfigValue moves value of the raw config from oldPath to newPath.
+// Value is not moved if newPath is already set.
+func moveConfigValue(raw map[string]interface{}, oldPath string, newPath string) int {
+	oldParent, oldKey := configPathParent(raw, oldPath, false)
+	if oldParent == nil {
+		return configValueMissing
+	}
+
+	value, ok := oldParent[oldKey]
+	if !ok {
+		return configValueMissing
+	}
+
+	delete(oldParent, oldKey)
+
+	newParent, newKey := configPathParent(raw, newPath, true)
+	if newParent == nil {
+		return configValueConflict
+	}
+
+	if _, exists := newParent[newKey]; exists {
+		return configValueConflict
+	}
+
+	newParent[newKey] = value
+	return configValueMoved
+}
+
+// configPathParent returns map containing the last key of the dot separated path.
+// Missing intermediate maps are created if create is true.
+func configPathParent(raw map[string]interface{}, path string, create bool) (map[string]interface{}, string) {
+	keys := strings.Split(path, ".")
+	parent := raw
+
+	for _, key := range keys[:len(keys)-1] {
+		child, exists := parent[key]
+		if !exists && create {
+			child = make(map[string]interface{})
+			parent[key] = child
+		}
+
+		childMap, ok := child.(map[string]interface{})
+		if !ok {
+			return nil, ""
+		}
+		parent = childMap
+	}
+
+	return parent, keys[len(keys)-1]
+}
+
+// yamlToJSONValue converts maps with non-string keys, e.g. project IDs
+// of project_git_client, to maps which can be marshaled to JSON.
+func yamlToJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = yamlToJSONValue(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			res[fmt.Sprint(key)] = yamlToJSONValue(item)
+		}
+		return res
+	case []interface{}:
+		for i, item := range v {
+			v[i] = yamlToJSONValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// getConfigFormat returns format of the config file. Format can be forced
+// by SEMAPHORE_CONFIG_FORMAT, otherwise it is detected by file extension.
+// Unknown extensions are treated as JSON.
+func getConfigFormat(configPath string) (string, error) {
+	format := strings.ToLower(os.Getenv("SEMAPHORE_CONFIG_FORMAT"))
+
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(configPath)), ".")
+		if _, ok := configDecoders[format]; !ok {
+			format = ConfigFormatJSON
+		}
+		return format, nil
+	}
+
+	if _, ok := configDecoders[format]; !ok {
+		return "", fmt.Errorf("unsupported config format '%s' set in SEMAPHORE_CONFIG_FORMAT", format)
+	}
+
+	return format, nil
+}
+
+func getConfigFormatOrExit(configPath string) string {
+	format, err := getConfigFormat(configPath)
+	if err != nil {
+		exitOnConfigError(err.Error())
+	}
+	return format
+}
+
+// findConfigField looks up a field by dot separated path. Each path
+// element may be either Go field name or its json name.
+func findConfigField(obj reflect.Value, path string) reflect.Value {
+	attribute := obj
+
+	for _, nested := range strings.Split(path, ".") {
+		attribute = reflect.Indirect(attribute)
+		if attribute.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+
+		t := attribute.Type()
+		found := false
+
+		for i := 0; i < t.NumField(); i++ {
+			jsonName := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if t.Field(i).Name == nested || jsonName == nested {
+				attribute = attribute.Field(i)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return reflect.Value{}
+		}
+	}
+
+	return attribute
+}
+
+// loadDirectoryToObject reads each file of the directory as a config value.
+// File name is the field path, e.g. `port` or `mysql.host`.
+func loadDirectoryToObject(obj interface{}, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// skip Kubernetes service entries like ..data
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		attribute := findConfigField(reflect.ValueOf(obj), name)
+		if !attribute.IsValid() {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+
+		if err = setConfigValue(attribute, strings.TrimRight(string(content), "\r\n")); err != nil {
+			return fmt.Errorf("value of field '%v' in directory %s is not valid: %v", name, dir, err)
+		}
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown config fields in directory %s: %s", dir, strings.Join(unknown, ", "))
+	}
+
+	return nil
+}
+
+// configSecrets are keys stored in SecretsFile
+type configSecrets struct {
+	CookieHash          string `json:"cookie_hash,omitempty"`
+	CookieEncryption    string `json:"cookie_encryption,omitempty"`
+	AccessKeyEncryption string `json:"access_key_encryption,omitempty"`
+}
+
+// loadSecretsFileToObject reads keys from SecretsFile of the config
+func loadSecretsFileToObject(conf *ConfigType) error {
+	if conf.SecretsFile == "" {
+		return nil
+	}
+
+	bytes, err := os.ReadFile(conf.SecretsFile)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(conf.SecretsFile); err == nil && info.Mode().Perm()&0077 != 0 {
+		log.Warnf("Secrets file %s is accessible by other users, its mode should be 0600", conf.SecretsFile)
+	}
+
+	var secrets configSecrets
+	if err = json.Unmarshal(bytes, &secrets); err != nil {
+		return fmt.Errorf("could not decode secrets file %s: %v", conf.SecretsFile, err)
+	}
+
+	if secrets.CookieHash != "" {
+		conf.CookieHash = secrets.CookieHash
+	}
+	if secrets.CookieEncryption != "" {
+		conf.CookieEncryption = secrets.CookieEncryption
+	}
+	if secrets.AccessKeyEncryption != "" {
+		conf.AccessKeyEncryption = secrets.AccessKeyEncryption
+	}
+
+	return nil
+}
+
+func loadConfigSecretsFile() {
+	if err := loadSecretsFileToObject(Config); err != nil {
+		exitOnConfigError(err.Error())
+	}
+}
+
+// WriteSecretsFile moves secret keys of the config to a separate file
+// with mode 0600. Config keeps only the path to the file.
+func (conf *ConfigType) WriteSecretsFile(secretsPath string) error {
+	bytes, err := json.MarshalIndent(configSecrets{
+		CookieHash:          conf.CookieHash,
+		CookieEncryption:    conf.CookieEncryption,
+		AccessKeyEncryption: conf.AccessKeyEncryption,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = os.WriteFile(secretsPath, bytes, 0600); err != nil {
+		return err
+	}
+
+	// WriteFile doesn't change mode of existing file
+	if err = os.Chmod(secretsPath, 0600); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(secretsPath)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm() != 0600 {
+		return fmt.Errorf("secrets file %s has mode %v instead of 0600", secretsPath, info.Mode().Perm())
+	}
+
+	conf.SecretsFile = secretsPath
+	conf.CookieHash = ""
+	conf.CookieEncryption = ""
+	conf.AccessKeyEncryption = ""
+
+	return nil
+}
+
+func loadConfigDirectory() {
+	dir := os.Getenv("SEMAPHORE_CONFIG_DIR")
+	if dir == "" {
+		return
+	}
+
+	err := loadDirectoryToObject(Config, dir)
+	if err != nil {
+		exitOnConfigError(err.Error())
+	}
+}
+
+// walkStringFields calls fn for each string value of obj including values
+// nested in structs, maps and slices. Path is built from Go field names,
+// map keys and slice indexes, e.g. `OidcProviders.google.Scopes[0]`.
+// Values stored in maps are written back after fn is called for them.
+func walkStringFields(obj interface{}, fn func(path string, get func() string, set func(string))) {
+	walkStringValue(reflect.ValueOf(obj), "", fn)
+}
+
+func joinConfigPath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func walkStringValue(v reflect.Value, path string, fn func(path string, get func() string, set func(string))) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		walkStringValue(v.Elem(), path, fn)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			walkStringValue(v.Field(i), joinConfigPath(path, t.Field(i).Name), fn)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, key := range keys {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			walkStringValue(elem, joinConfigPath(path, fmt.Sprint(key)), fn)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStringValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case reflect.String:
+		fn(path, v.String, func(value string) {
+			if v.CanSet() {
+				v.SetString(value)
+			}
+		})
+	}
+}
+
+func loadDefaultsToObject(obj interface{}) error {
+	var t = reflect.TypeOf(obj)
+	var v = reflect.ValueOf(obj)
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		v = reflect.Indirect(v)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		fieldInfo := t.Field(i)
+		fieldValue := v.Field(i)
+
+		if !fieldValue.IsZero() && fieldInfo.Type.Kind() != reflect.Struct && fieldInfo.Type.Kind() != reflect.Map {
+			continue
+		}
+
+		if fieldInfo.Type.Kind() == reflect.Struct {
+			err := loadDefaultsToObject(fieldValue.Addr().Interface())
+			if err != nil {
+				return err
+			}
+			continue
+		} else if fieldInfo.Type.Kind() == reflect.Map {
+			if defaultVar := fieldInfo.Tag.Get("default"); fieldValue.Len() == 0 && defaultVar != "" {
+				if err := setConfigValue(fieldValue, defaultVar); err != nil {
+					return fmt.Errorf("default value of field '%v' is not valid: %v", fieldInfo.Name, err)
+				}
+				continue
+			}
+
+			for _, key := range fieldValue.MapKeys() {
+				val := fieldValue.MapIndex(key)
+
+				if val.Type().Kind() != reflect.Struct {
+					continue
+				}
+
+				newVal := reflect.New(val.Type())
+				pointerValue := newVal.Elem()
+				pointerValue.Set(val)
+
+				err := loadDefaultsToObject(newVal.Interface())
+				if err != nil {
+					return err
+				}
+
+				fieldValue.SetMapIndex(key, newVal.Elem())
+			}
+			continue
+		}
+
+		defaultVar := fieldInfo.Tag.Get("default")
+		if defaultVar == "" {
+			continue
+		}
+
+		if err := setConfigValue(fieldValue, defaultVar); err != nil {
+			return fmt.Errorf("default value of field '%v' is not valid: %v", fieldInfo.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// loadConfigDefaultsToObject applies default tags and defaults which
+// depend on other config values.
+func loadConfigDefaultsToObject(conf *ConfigType) error {
+	if err := loadDefaultsToObject(conf); err != nil {
+		return err
+	}
+
+	loadOidcDefaultsToObject(conf)
+	return nil
+}
+
+// defaultOidcScopes are requested if scopes of OIDC provider are not set.
+// Discovery fails without "openid" scope.
+var defaultOidcScopes = []string{"openid", "profile", "email"}
+
+// loadOidcDefaultsToObject sets default scopes of OIDC providers and
+// enables PKCE for providers without client secret.
+func loadOidcDefaultsToObject(conf *ConfigType) {
+	for name, provider := range conf.OidcProviders {
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = append([]string{}, defaultOidcScopes...)
+		}
+		if provider.ClientSecret == "" {
+			provider.UsePKCE = true
+		}
+		conf.OidcProviders[name] = provider
+	}
+}
+
+func loadConfigDefaults() {
+
+	err := loadConfigDefaultsToObject(Config)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func castStringToInt(value string) (int, error) {
+
+	valueInt, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid integer", value)
+	}
+	return valueInt, nil
+
+}
+
+func castStringToSlice(value string) []string {
+
+	valueSlice := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		valueSlice = append(valueSlice, item)
+	}
+	return valueSlice
+
+}
+
+// castStringToMap parses JSON object or comma separated `key=value` or `key: value` pairs.
+// Item without separator is a key with empty value.
+func castStringToMap(value string) map[string]string {
+
+	valueMap := make(map[string]string)
+	if strings.HasPrefix(strings.TrimSpace(value), "{") && json.Unmarshal([]byte(value), &valueMap) == nil {
+		return valueMap
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, val := item, ""
+		if i := strings.IndexAny(item, "=:"); i >= 0 {
+			key, val = item[:i], item[i+1:]
+		}
+		valueMap[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return valueMap
+
+}
+
+// castToConfigMap converts JSON object, map[string]string or string parsed by
+// castStringToMap to the map type of config field, e.g. map[int]GitClientId.
+// Only string and integer keys and values are supported.
+func castToConfigMap(mapType reflect.Type, value interface{}) (interface{}, error) {
+	var items map[string]string
+
+	switch v := value.(type) {
+	case map[string]string:
+		items = v
+	case map[string]interface{}:
+		items = make(map[string]string, len(v))
+		for key, item := range v {
+			items[key] = fmt.Sprintf("%v", item)
+		}
+	default:
+		items = castStringToMap(fmt.Sprintf("%v", value))
+	}
+
+	valueMap := reflect.MakeMapWithSize(mapType, len(items))
+	for key, item := range items {
+		mapKey, err := castToConfigMapItem(mapType.Key(), key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid: %v", key, err)
+		}
+		mapItem, err := castToConfigMapItem(mapType.Elem(), item)
+		if err != nil {
+			return nil, fmt.Errorf("value of key %q is not valid: %v", key, err)
+		}
+		valueMap.SetMapIndex(mapKey, mapItem)
+	}
+
+	return valueMap.Interface(), nil
+}
+
+func castToConfigMapItem(itemType reflect.Type, value string) (reflect.Value, error) {
+	switch itemType.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(value).Convert(itemType), nil
+	case reflect.Int:
+		valueInt, err := castStringToInt(value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(valueInt).Convert(itemType), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("%v is not supported", itemType)
+	}
+}
+
+// castStringToBool returns error for values which are not booleans.
+// Spellings like on/off and enabled/disabled are accepted for compatibility
+// with older configs.
+func castStringToBool(value string) (bool, error) {
+
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "y", "on", "enabled":
+		return true, nil
+	case "", "0", "false", "no", "n", "off", "disabled":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%q is not a valid boolean, expected true or false", value)
+	}
+
+}
+
+func castStringToDuration(value string) (time.Duration, error) {
+
+	valueDuration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration, expected value like 30s or 5m", value)
+	}
+	return valueDuration, nil
+
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setConfigValue converts value to the type of attribute and sets it.
+// It returns error if the value can't be converted.
+func setConfigValue(attribute reflect.Value, value interface{}) (err error) {
+
+	if attribute.IsValid() {
+		switch {
+		case attribute.Type() == durationType:
+			if _, ok := value.(time.Duration); !ok {
+				value, err = castStringToDuration(fmt.Sprintf("%v", reflect.ValueOf(value)))
+			}
+		case attribute.Kind() == reflect.Int:
+			if reflect.ValueOf(value).Kind() != reflect.Int {
+				value, err = castStringToInt(fmt.Sprintf("%v", reflect.ValueOf(value)))
+			}
+		case attribute.Kind() == reflect.Bool:
+			if reflect.ValueOf(value).Kind() != reflect.Bool {
+				value, err = castStringToBool(fmt.Sprintf("%v", reflect.ValueOf(value)))
+			}
+		case attribute.Kind() == reflect.String:
+			// allows to set named string types like GitClientId
+			value = reflect.ValueOf(fmt.Sprintf("%v", value)).Convert(attribute.Type()).Interface()
+		case attribute.Kind() == reflect.Map:
+			if reflect.TypeOf(value) != attribute.Type() {
+				value, err = castToConfigMap(attribute.Type(), value)
+			}
+		case attribute.Kind() == reflect.Slice:
+			if items, ok := value.([]interface{}); ok {
+				valueSlice := make([]string, 0, len(items))
+				for _, item := range items {
+					valueSlice = append(valueSlice, fmt.Sprintf("%v", item))
+				}
+				value = valueSlice
+			} else if reflect.ValueOf(value).Kind() != reflect.Slice {
+				value = castStringToSlice(fmt.Sprintf("%v", reflect.ValueOf(value)))
+			}
+		}
+		if err != nil {
+			return
+		}
+		attribute.Set(reflect.ValueOf(value))
+	} else {
+		panic(fmt.Errorf("got non-existent config attribute"))
+	}
+
+	return
+}
+
+func getConfigValue(path string) string {
+
+	attribute := reflect.ValueOf(Config)
+	nested_path := strings.Split(path, ".")
+
+	for i, nested := range nested_path {
+		attribute = reflect.Indirect(attribute).FieldByName(nested)
+		lastDepth := len(nested_path) == i+1
+		if !lastDepth && attribute.Kind() != reflect.Struct || lastDepth && attribute.Kind() == reflect.Invalid {
+			panic(fmt.Errorf("got non-existent config attribute '%v'", path))
+		}
+	}
+
+	return fmt.Sprintf("%v", attribute)
+}
+
+func validate(value interface{}) error {
+	if errs := validateRules(value); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// validateRules checks all fields of value against their `rule` regexes
+func validateRules(value interface{}) (errs []error) {
+	var t = reflect.TypeOf(value)
+	var v = reflect.ValueOf(value)
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		v = reflect.Indirect(v)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		fieldType := t.Field(i)
+		fieldValue := v.Field(i)
+
+		rule := fieldType.Tag.Get("rule")
+		if rule == "" {
+			continue
+		}
+
+		var strVal string
+
+		if fieldType.Type.Kind() == reflect.Int {
+			strVal = strconv.FormatInt(fieldValue.Int(), 10)
+		} else if fieldType.Type.Kind() == reflect.Uint {
+			strVal = strconv.FormatUint(fieldValue.Uint(), 10)
+		} else {
+			strVal = fieldValue.String()
+		}
+
+		match, _ := regexp.MatchString(rule, strVal)
+
+		if match {
+			continue
+		}
+
+		fieldName := strings.ToLower(fieldType.Name)
+
+		if strings.Contains(fieldName, "password") || strings.Contains(fieldName, "secret") || strings.Contains(fieldName, "key") {
+			strVal = "***"
+		}
+
+		errs = append(errs, fmt.Errorf(
+			"value of field '%v' is not valid: %v (Must match regex: '%v')",
+			fieldType.Name, strVal, rule,
+		))
+	}
+
+	return
+}
+
+// configValidators are cross-field checks which can't be expressed
+// by a single `rule` regex.
+var configValidators = []func(conf *ConfigType) error{
+	validateURLFields,
+	validateAllowedEmailDomains,
+	validateEmail,
+	validateLdap,
+	validateLdapGroupMappings,
+	validateProjectGitClient,
+	validateSecretKeys,
+	validateEnvOverrideDenylist,
+	validateSecretsPresent,
+	validateAccessKeyEncryption,
+	validateLoginBanner,
+	validateDbConfigs,
+	validateRequireDbTLS,
+	validateOidcRedirectURLs,
+	validateSlackUrls,
+	validateTelegram,
+	validateOidcProviders,
+	validateBindAddress,
+	validateAnsibleExecutionEnvironment,
+	validateAnsibleModuleDenylist,
+	validateMaxParallelTasks,
+	validateWebhookHeaders,
+}
+
+// validateConfigErrors returns all problems of the config
+func validateConfigErrors(conf *ConfigType) []error {
+	errs := validateRules(conf)
+
+	for _, validator := range configValidators {
+		if err := validator(conf); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// joinConfigErrors combines errors to one error with message per line
+func joinConfigErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return errors.New(strings.Join(messages, "\n"))
+}
+
+func validateConfigObject(conf *ConfigType) error {
+	return joinConfigErrors(conf.Validate())
+}
+
+// Validate normalizes Port and checks the config.
+// Unlike ConfigInit it returns all found problems instead of exiting.
+func (conf *ConfigType) Validate() []error {
+	if conf.Port != "" && !strings.HasPrefix(conf.Port, ":") {
+		conf.Port = ":" + conf.Port
+	}
+
+	return validateConfigErrors(conf)
+}
+
+// ValidateConfigFile loads config from the file only, without environment,
+// secrets file and config directory, and returns all its problems.
+// Unlike ConfigInit it doesn't change Config and doesn't exit.
+func ValidateConfigFile(configPath string) []error {
+	format, err := getConfigFormat(configPath)
+	if err != nil {
+		return []error{err}
+	}
+
+	file, err := os.Open(configPath)
+	if err != nil {
+		return []error{err}
+	}
+	defer file.Close() //nolint:errcheck
+
+	conf := new(ConfigType)
+
+	if err = decodeConfigObject(file, format, conf); err != nil {
+		return []error{fmt.Errorf("could not decode configuration file %s: %v", configPath, err)}
+	}
+
+	if err = loadConfigDefaultsToObject(conf); err != nil {
+		return []error{err}
+	}
+
+	return conf.Validate()
+}
+
+// ValidateConfig returns all problems of the loaded config
+// or nil if the config is valid.
+func ValidateConfig() []error {
+	return Config.Validate()
+}
+
+func validateConfig() {
+
+	err := joinConfigErrors(Config.Validate())
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+func validateProjectGitClient(conf *ConfigType) error {
+	for projectID, client := range conf.ProjectGitClient {
+		switch client {
+		case GoGitClientId, CmdGitClientId:
+		default:
+			return fmt.Errorf("value of field 'ProjectGitClient' is not valid: unknown git client '%v' for project %d", client, projectID)
+		}
+	}
+	return nil
+}
+
+// secretKeyLengths lists allowed decoded lengths of secret keys
+var secretKeyLengths = []struct {
+	name    string
+	get     func(conf *ConfigType) string
+	lengths []int
+}{
+	{"CookieHash", func(conf *ConfigType) string { return conf.CookieHash }, []int{32, 64}},
+	{"CookieEncryption", func(conf *ConfigType) string { return conf.CookieEncryption }, []int{16, 24, 32}},
+	{"AccessKeyEncryption", func(conf *ConfigType) string { return conf.AccessKeyEncryption }, []int{16, 24, 32}},
+}
+
+// validateSecretKeys checks that secret keys are valid base64 and decode
+// to lengths accepted by securecookie and AES. In lenient mode problems
+// are only logged.
+func validateSecretKeys(conf *ConfigType) error {
+	var errs []error
+
+	for _, key := range secretKeyLengths {
+		value := key.get(conf)
+		if value == "" {
+			continue
+		}
+
+		errs = append(errs, validateSecretKey(key.name, value, key.lengths))
+	}
+
+	for _, key := range conf.CookieVerifyKeys {
+		hash, encryption := splitCookieVerifyKey(key)
+
+		errs = append(errs, validateSecretKey("CookieVerifyKeys", hash, secretKeyLengths[0].lengths))
+
+		if encryption != "" {
+			errs = append(errs, validateSecretKey("CookieVerifyKeys", encryption, secretKeyLengths[1].lengths))
+		}
+	}
+
+	if len(conf.AccessKeyEncryptionOld) > 0 && conf.AccessKeyEncryption == "" {
+		errs = append(errs, errors.New("value of field 'AccessKeyEncryptionOld' is not valid: AccessKeyEncryption is required for key rotation"))
+	}
+
+	for _, key := range conf.AccessKeyEncryptionOld {
+		errs = append(errs, validateSecretKey("AccessKeyEncryptionOld", key, secretKeyLengths[2].lengths))
+	}
+
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if !conf.LenientSecretValidation {
+			return err
+		}
+
+		LogWarning(err)
+	}
+
+	return nil
+}
+
+func validateSecretKey(name string, value string, lengths []int) error {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("value of field '%v' is not valid base64: %v", name, err)
+	}
+
+	for _, length := range lengths {
+		if len(decoded) == length {
+			return nil
+		}
+	}
+
+	allowed := make([]string, len(lengths))
+	for i, length := range lengths {
+		allowed[i] = strconv.Itoa(length)
+	}
+
+	return fmt.Errorf(
+		"value of field '%v' has wrong length: decoded key must be %v bytes long, got %d",
+		name, strings.Join(allowed, " or "), len(decoded),
+	)
+}
+
+func validateEnvOverrideDenylist(conf *ConfigType) error {
+	for _, fieldPath := range conf.EnvOverrideDenylist {
+		t := reflect.TypeOf(*conf)
+		for _, name := range strings.Split(fieldPath, ".") {
+			if t.Kind() != reflect.Struct {
+				return fmt.Errorf("value of field 'EnvOverrideDenylist' is not valid: unknown field '%v'", fieldPath)
+			}
+			field, ok := t.FieldByName(name)
+			if !ok {
+				return fmt.Errorf("value of field 'EnvOverrideDenylist' is not valid: unknown field '%v'", fieldPath)
+			}
+			t = field.Type
+		}
+	}
+	return nil
+}
+
+// validateSecretsPresent requires all secret keys to be set unless DevMode is on.
+// Runners don't use the keys, so their configs are skipped.
+func validateSecretsPresent(conf *ConfigType) error {
+	if conf.DevMode || conf.Runner.ApiURL != "" {
+		return nil
+	}
+
+	secrets := []struct {
+		name  string
+		value string
+	}{
+		{"CookieHash", conf.CookieHash},
+		{"CookieEncryption", conf.CookieEncryption},
+		{"AccessKeyEncryption", conf.AccessKeyEncryption},
+	}
+
+	for _, secret := range secrets {
+		if secret.value == "" {
+			return fmt.Errorf("value of field '%v' is empty: secret keys are required unless dev_mode is enabled", secret.name)
+		}
+	}
+
+	return nil
+}
+
+const (
+	maxLoginBannerTitleLength = 100
+	maxLoginBannerTextLength  = 4000
+)
+
+func validateLoginBanner(conf *ConfigType) error {
+	if utf8.RuneCountInString(conf.LoginBannerTitle) > maxLoginBannerTitleLength {
+		return fmt.Errorf("value of field 'LoginBannerTitle' is too long: max length is %d", maxLoginBannerTitleLength)
+	}
+	if utf8.RuneCountInString(conf.LoginBannerText) > maxLoginBannerTextLength {
+		return fmt.Errorf("value of field 'LoginBannerText' is too long: max length is %d", maxLoginBannerTextLength)
+	}
+	return nil
+}
+
+var containerImageRegex = regexp.MustCompile(
+	`^[a-z0-9]+([._-][a-z0-9]+)*(:[0-9]+)?(/[a-z0-9]+([._-][a-z0-9]+)*)*(:[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(@sha256:[a-f0-9]{64})?$`,
+)
+
+func validateAnsibleExecutionEnvironment(conf *ConfigType) error {
+	if conf.AnsibleExecutionEnvironment == "" {
+		return nil
+	}
+
+	if conf.AnsibleRunnerImage == "" {
+		return fmt.Errorf("value of field 'AnsibleRunnerImage' is required if ansible_execution_environment is set")
+	}
+
+	if !containerImageRegex.MatchString(conf.AnsibleRunnerImage) {
+		return fmt.Errorf("value of field 'AnsibleRunnerImage' is not valid: %v is not an image reference", conf.AnsibleRunnerImage)
+	}
+
+	return nil
+}
+
+var ansibleModuleRegex = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+){0,2}$`)
+
+func validateAnsibleModuleDenylist(conf *ConfigType) error {
+	for _, module := range conf.AnsibleModuleDenylist {
+		if !ansibleModuleRegex.MatchString(module) {
+			return fmt.Errorf("value of field 'AnsibleModuleDenylist' is not valid: %v is not a module name", module)
+		}
+	}
+	return nil
+}
+
+var httpHeaderNameRegex = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+func validateWebhookHeaders(conf *ConfigType) error {
+	for name, value := range conf.WebhookHeaders {
+		if !httpHeaderNameRegex.MatchString(name) {
+			return fmt.Errorf("value of field 'WebhookHeaders' is not valid: '%v' is not valid header name", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("value of field 'WebhookHeaders' is not valid: value of header '%v' contains line break", name)
+		}
+	}
+	return nil
+}
+
+func validateMaxParallelTasks(conf *ConfigType) error {
+	if conf.MaxParallelTasks < 0 {
+		return fmt.Errorf("value of field 'MaxParallelTasks' is not valid: %d (must be non-negative, 0 means unlimited)",
+			conf.MaxParallelTasks)
+	}
+	return nil
+}
+
+func validateAccessKeyEncryption(conf *ConfigType) error {
+	if conf.RequireAccessKeyEncryption && conf.AccessKeyEncryption == "" {
+		return errors.New("value of field 'AccessKeyEncryption' is empty: it is required by require_access_key_encryption")
+	}
+	return nil
+}
+
+// warnUnencryptedAccessKeys warns that access keys are stored unencrypted
+// if AccessKeyEncryption is empty, which is allowed in dev mode.
+func warnUnencryptedAccessKeys(conf *ConfigType) {
+	if conf.AccessKeyEncryption == "" && conf.Runner.ApiURL == "" {
+		log.Warn("AccessKeyEncryption is empty, access keys are stored in database UNENCRYPTED. " +
+			"Set access_key_encryption, and require_access_key_encryption to make it mandatory")
+	}
+}
+
+// warnUnlimitedParallelTasks warns that MaxParallelTasks 0 doesn't limit running tasks
+func warnUnlimitedParallelTasks() {
+	if Config.MaxParallelTasks == 0 {
+		log.Warn("MaxParallelTasks is 0, number of tasks running in parallel is unlimited")
+	}
+}
+
+var dbApplicationNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{0,63}$`)
+
+var dbTLSModeRegex = regexp.MustCompile(`^(|disabled|skip-verify|verify-full)$`)
+
+var dbSslModeRegex = regexp.MustCompile(`^(|disable|allow|prefer|require|verify-ca|verify-full)$`)
+
+func validateDbConfigs(conf *ConfigType) error {
+	dbConfigs := map[string]DbConfig{
+		"MySQL":    conf.MySQL,
+		"BoltDb":   conf.BoltDb,
+		"Postgres": conf.Postgres,
+	}
+
+	var errs []error
+
+	for _, name := range []string{"MySQL", "BoltDb", "Postgres"} {
+		dbConfig := dbConfigs[name]
+		if dbConfig.StatementTimeoutSeconds < 0 {
+			errs = append(errs, fmt.Errorf("value of field '%v.StatementTimeoutSeconds' is not valid: must be non-negative", name))
+		}
+		if !dbSslModeRegex.MatchString(dbConfig.SslMode) {
+			errs = append(errs, fmt.Errorf("value of field '%v.SslMode' is not valid: %v (Must match regex: '%v')",
+				name, dbConfig.SslMode, dbSslModeRegex))
+		}
+		if dbConfig.Port < 0 || dbConfig.Port > 65535 {
+			errs = append(errs, fmt.Errorf("value of field '%v.Port' is not valid: %d (must be between 0 and 65535)", name, dbConfig.Port))
+		}
+		if dbConfig.MaxOpenConns < 0 {
+			errs = append(errs, fmt.Errorf("value of field '%v.MaxOpenConns' is not valid: must be non-negative", name))
+		}
+		if pool, err := dbConfig.GetPoolSettings(); err != nil || pool.ConnMaxLifetime < 0 {
+			errs = append(errs, fmt.Errorf("value of field '%v.ConnMaxLifetime' is not valid: %v (must be non-negative duration, e.g. 30m)",
+				name, dbConfig.ConnMaxLifetime))
+		}
+		if !dbTLSModeRegex.MatchString(dbConfig.TLSMode) {
+			errs = append(errs, fmt.Errorf("value of field '%v.TLSMode' is not valid: %v (Must match regex: '%v')",
+				name, dbConfig.TLSMode, dbTLSModeRegex))
+		}
+		if (dbConfig.TLSCert == "") != (dbConfig.TLSKey == "") {
+			errs = append(errs, fmt.Errorf("value of field '%v.TLSCert' is not valid: TLSCert and TLSKey must be set together", name))
+		}
+		if !dbApplicationNameRegex.MatchString(dbConfig.ApplicationName) {
+			errs = append(errs, fmt.Errorf("value of field '%v.ApplicationName' is not valid: %v (Must match regex: '%v')",
+				name, dbConfig.ApplicationName, dbApplicationNameRegex))
+		}
+	}
+
+	var populated []string
+	for _, name := range []string{"MySQL", "BoltDb", "Postgres"} {
+		if dbConfigs[name].Hostname != "" {
+			populated = append(populated, name)
+		}
+	}
+
+	if len(populated) > 1 {
+		log.Warnf("Multiple database configurations are populated (%v), only one of them will be used",
+			strings.Join(populated, ", "))
+	}
+
+	if conf.Dialect == "" {
+		return joinConfigErrors(errs)
+	}
+
+	dialectConfigs := map[string]string{
+		DbDriverMySQL:    "MySQL",
+		DbDriverBolt:     "BoltDb",
+		DbDriverPostgres: "Postgres",
+	}
+
+	name, ok := dialectConfigs[conf.Dialect]
+	if !ok {
+		return joinConfigErrors(errs)
+	}
+
+	dbConfig := dbConfigs[name]
+	if !dbConfig.IsPresent() {
+		errs = append(errs, fmt.Errorf("value of field 'Dialect' is not valid: dialect is %v but '%v' database configuration is empty",
+			conf.Dialect, name))
+		return joinConfigErrors(errs)
+	}
+
+	dbConfig.Dialect = conf.Dialect
+	if dbConfig.IsUnixSocket() {
+		if _, err := os.Stat(dbConfig.GetHostname()); err != nil {
+			errs = append(errs, fmt.Errorf("value of field '%v.Hostname' is not valid: socket %v is not accessible: %v",
+				name, dbConfig.GetHostname(), err))
+		}
+	}
+
+	return joinConfigErrors(errs)
+}
+
+func validateRequireDbTLS(conf *ConfigType) error {
+	if !conf.RequireDbTLS {
+		return nil
+	}
+
+	dialect, err := conf.GetDialect()
+	if err != nil {
+		return nil
+	}
+
+	if dialect == DbDriverBolt {
+		return fmt.Errorf("value of field 'RequireDbTLS' is not valid: TLS is not supported by %v database", dialect)
+	}
+
+	return nil
+}
+
+// urlOrigin returns scheme://host[:port] of the URL in lower case
+func urlOrigin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// validateOidcRedirectURLs checks that OIDC redirect URLs point to WebHost
+// because state cookie is not sent to other origins.
+func validateOidcRedirectURLs(conf *ConfigType) error {
+	if conf.WebHost == "" {
+		return nil
+	}
+
+	webHost, err := url.Parse(conf.WebHost)
+	if err != nil {
+		return fmt.Errorf("value of field 'WebHost' is not valid: %v", err)
+	}
+
+	names := make([]string, 0, len(conf.OidcProviders))
+	for name := range conf.OidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		provider := conf.OidcProviders[name]
+		if provider.RedirectURL == "" {
+			continue
+		}
+
+		redirectURL, err := url.Parse(provider.RedirectURL)
+		if err != nil {
+			return fmt.Errorf("redirect_url of OIDC provider '%v' is not valid: %v", name, err)
+		}
+
+		if urlOrigin(redirectURL) != urlOrigin(webHost) {
+			return fmt.Errorf(
+				"redirect_url of OIDC provider '%v' has origin %v which doesn't match web_host origin %v",
+				name, urlOrigin(redirectURL), urlOrigin(webHost),
+			)
+		}
+	}
+
+	return nil
+}
+
+// validateHTTPURL checks that value is absolute http(s) URL
+func validateHTTPURL(fieldName string, value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("value of field '%v' is not valid URL: %v", fieldName, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("value of field '%v' is not valid: %v is not absolute http(s) URL", fieldName, value)
+	}
+	return nil
+}
+
+// urlConfigField describes config field which contains URL
+type urlConfigField struct {
+	schemes []string
+	// canonicalize is false for fields compared verbatim, like OIDC issuer
+	canonicalize bool
+}
+
+var httpSchemes = []string{"http", "https"}
+
+// urlConfigFields are paths of fields which contain URLs.
+// `*` matches a key of map, e.g. name of OIDC provider.
+var urlConfigFields = map[string]urlConfigField{
+	"WebHost":                              {httpSchemes, true},
+	"SlackUrl":                             {httpSchemes, true},
+	"SlackUrls":                            {httpSchemes, true},
+	"DiscordUrl":                           {httpSchemes, true},
+	"MsTeamsUrl":                           {httpSchemes, true},
+	"WebhookUrl":                           {httpSchemes, true},
+	"WebhookUrls":                          {httpSchemes, true},
+	"AlertUrlProxy":                        {[]string{"http", "https", "socks5"}, true},
+	"Runner.ApiURL":                        {httpSchemes, true},
+	"Runner.Webhook":                       {httpSchemes, true},
+	"OidcProviders.*.RedirectURL":          {httpSchemes, true},
+	"OidcProviders.*.AutoDiscovery":        {httpSchemes, false},
+	"OidcProviders.*.ExpectedIssuer":       {httpSchemes, false},
+	"OidcProviders.*.Endpoint.IssuerURL":   {httpSchemes, false},
+	"OidcProviders.*.Endpoint.AuthURL":     {httpSchemes, true},
+	"OidcProviders.*.Endpoint.TokenURL":    {httpSchemes, true},
+	"OidcProviders.*.Endpoint.UserInfoURL": {httpSchemes, true},
+	"OidcProviders.*.Endpoint.JWKSURL":     {httpSchemes, true},
+}
+
+var configPathIndexRegex = regexp.MustCompile(`\[[0-9]+\]`)
+
+// findURLConfigField returns description of the URL field by its path
+func findURLConfigField(fieldPath string) (urlConfigField, bool) {
+	fieldPath = configPathIndexRegex.ReplaceAllString(fieldPath, "")
+
+	for pattern, field := range urlConfigFields {
+		if matched, _ := path.Match(pattern, fieldPath); matched {
+			return field, true
+		}
+	}
+
+	return urlConfigField{}, false
+}
+
+// canonicalizeURL lower cases scheme and host and removes default port
+func canonicalizeURL(u *url.URL) string {
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		u.Host = "[" + host + "]"
+	} else {
+		u.Host = host
+	}
+
+	return u.String()
+}
+
+// validateURLFields checks all fields listed in urlConfigFields are absolute
+// URLs with allowed scheme and canonicalizes them.
+func validateURLFields(conf *ConfigType) error {
+	var problems []string
+
+	walkStringFields(conf, func(fieldPath string, get func() string, set func(string)) {
+		field, ok := findURLConfigField(fieldPath)
+		if !ok || get() == "" {
+			return
+		}
+
+		u, err := url.Parse(get())
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("value of field '%v' is not valid URL: %v", fieldPath, err))
+			return
+		}
+
+		schemeAllowed := false
+		for _, scheme := range field.schemes {
+			if strings.EqualFold(u.Scheme, scheme) {
+				schemeAllowed = true
+				break
+			}
+		}
+
+		if !schemeAllowed || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("value of field '%v' is not valid: %v is not absolute %v URL",
+				fieldPath, get(), strings.Join(field.schemes, "/")))
+			return
+		}
+
+		if field.canonicalize {
+			set(canonicalizeURL(u))
+		}
+	})
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func validateSlackUrls(conf *ConfigType) error {
+	for _, slackUrl := range conf.GetSlackUrls() {
+		if err := validateHTTPURL("SlackUrls", slackUrl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateOidcProviders(conf *ConfigType) error {
+	for name, provider := range conf.OidcProviders {
+		if provider.ClientID == "" {
+			return fmt.Errorf("client_id of OIDC provider '%v' must not be empty", name)
+		}
+
+		// issuer of manual endpoints is checked below, it can be set by expected_issuer
+		if provider.AutoDiscovery == "" && (provider.Endpoint.AuthURL == "" || provider.Endpoint.TokenURL == "") {
+			return fmt.Errorf("OIDC provider '%v' requires provider_url or endpoint with auth and token URLs", name)
+		}
+
+		if provider.RedirectURL != "" {
+			if _, err := url.Parse(provider.RedirectURL); err != nil {
+				return fmt.Errorf("redirect_url of OIDC provider '%v' is not valid: %v", name, err)
+			}
+		}
+
+		if provider.ResponseType != "" && !containsString(oidcResponseTypes, provider.ResponseType) {
+			return fmt.Errorf("response_type of OIDC provider '%v' is not valid: must be one of %v",
+				name, strings.Join(oidcResponseTypes, ", "))
+		}
+
+		if !containsString(oidcResponseModes, provider.ResponseMode) {
+			return fmt.Errorf("response_mode of OIDC provider '%v' is not valid: must be empty or one of %v",
+				name, strings.Join(oidcResponseModes[1:], ", "))
+		}
+
+		issuer := provider.GetExpectedIssuer()
+
+		if issuer == "" {
+			if provider.AutoDiscovery == "" {
+				return fmt.Errorf("OIDC provider '%v' has manual endpoints, so expected_issuer or endpoint.issuer is required", name)
+			}
+			continue
+		}
+
+		if err := validateHTTPURL("OidcProviders."+name+".ExpectedIssuer", issuer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var domainRegex = regexp.MustCompile(`^([a-zA-Z0-9]+(-[a-zA-Z0-9]+)*\.)+[a-zA-Z]{2,}$`)
+
+func validateAllowedEmailDomains(conf *ConfigType) error {
+	for _, domain := range conf.AllowedEmailDomains {
+		if !domainRegex.MatchString(domain) {
+			return fmt.Errorf("value of field 'AllowedEmailDomains' is not valid: %v is not a domain", domain)
+		}
+	}
+	return nil
 }
 
 func loadEnvironmentToObject(obj interface{}) error {
+	return loadEnvironmentToObjectExcept(obj, "", nil)
+}
+
+// loadEnvironmentToObjectExcept loads environment variables to obj
+// skipping fields which paths (relative to obj) are listed in denylist.
+func loadEnvironmentToObjectExcept(obj interface{}, path string, denylist []string) error {
 	var t = reflect.TypeOf(obj)
 	var v = reflect.ValueOf(obj)
 
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
-		v = reflect.Indirect(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		v = reflect.Indirect(v)
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		fieldType := t.Field(i)
+		fieldValue := v.Field(i)
+		fieldPath := joinConfigPath(path, fieldType.Name)
+
+		if isFieldPathDenied(fieldPath, denylist) {
+			continue
+		}
+
+		if fieldType.Type.Kind() == reflect.Struct {
+			err := loadEnvironmentToObjectExcept(fieldValue.Addr().Interface(), fieldPath, denylist)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		envVar := fieldType.Tag.Get("env")
+		if envVar == "" {
+			continue
+		}
+
+		// Docker secrets: value of sensitive field can be read from file set in <env>_FILE
+		if isSecretConfigField(fieldType.Name) {
+			if filePath, exists := os.LookupEnv(envVar + "_FILE"); exists {
+				content, err := os.ReadFile(filePath)
+				if err != nil {
+					return fmt.Errorf("cannot read %s_FILE: %v", envVar, err)
+				}
+				if err = setConfigValue(fieldValue, strings.TrimRight(string(content), "\r\n")); err != nil {
+					return fmt.Errorf("value of %s_FILE is not valid: %v", envVar, err)
+				}
+				continue
+			}
+		}
+
+		envValue, exists := os.LookupEnv(envVar)
+
+		if !exists {
+			continue
+		}
+
+		if err := setConfigValue(fieldValue, envValue); err != nil {
+			return fmt.Errorf("value of %s is not valid: %v", envVar, err)
+		}
+	}
+
+	return nil
+}
+
+// nonEmptyConfigFieldPaths returns paths of fields of conf which have non-zero values.
+// Nested structs are not listed, only their non-empty fields.
+func nonEmptyConfigFieldPaths(conf *ConfigType) []string {
+	var paths []string
+	collectNonEmptyFieldPaths(reflect.ValueOf(conf).Elem(), "", &paths)
+	return paths
+}
+
+func collectNonEmptyFieldPaths(v reflect.Value, path string, paths *[]string) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+
+		fieldValue := v.Field(i)
+		fieldPath := joinConfigPath(path, t.Field(i).Name)
+
+		if fieldValue.Kind() == reflect.Struct {
+			collectNonEmptyFieldPaths(fieldValue, fieldPath, paths)
+			continue
+		}
+
+		switch fieldValue.Kind() {
+		case reflect.Map, reflect.Slice:
+			if fieldValue.Len() > 0 {
+				*paths = append(*paths, fieldPath)
+			}
+		default:
+			if !fieldValue.IsZero() {
+				*paths = append(*paths, fieldPath)
+			}
+		}
+	}
+}
+
+// isFieldPathDenied returns true if the field or any of its parents is in denylist
+func isFieldPathDenied(fieldPath string, denylist []string) bool {
+	for _, denied := range denylist {
+		if fieldPath == denied || strings.HasPrefix(fieldPath, denied+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func loadConfigEnvironment() {
+	if err := loadConfigEnvironmentToObject(Config); err != nil {
+		exitOnConfigError(err.Error())
+	}
+}
+
+// loadConfigEnvironmentToObject loads environment variables to conf
+// except fields listed in EnvOverrideDenylist and database configs
+// which are not used by the selected dialect.
+func loadConfigEnvironmentToObject(conf *ConfigType) error {
+	if precedence, exists := os.LookupEnv("SEMAPHORE_CONFIG_PRECEDENCE"); exists && !isFieldPathDenied("ConfigPrecedence", conf.EnvOverrideDenylist) {
+		conf.ConfigPrecedence = precedence
+	}
+
+	denylist := append([]string{}, conf.EnvOverrideDenylist...)
+
+	// environment fills only fields which are not set by the config file
+	if conf.GetConfigPrecedence() == ConfigPrecedenceFile {
+		denylist = append(denylist, nonEmptyConfigFieldPaths(conf)...)
+	}
+
+	denylist = append(denylist, dbEnvDenylist(conf, denylist)...)
+
+	if err := loadEnvironmentToObjectExcept(conf, "", denylist); err != nil {
+		return err
+	}
+
+	if err := loadDbEnvironmentToObject(conf, denylist); err != nil {
+		return err
+	}
+
+	if isFieldPathDenied("OidcProviders", denylist) {
+		return nil
+	}
+
+	return loadOidcEnvironmentToObject(conf)
+}
+
+// DefaultOidcProviderKey is key of OidcProviders for the provider configured from environment
+const DefaultOidcProviderKey = "default"
+
+// loadOidcEnvironmentToObject adds OIDC provider configured by SEMAPHORE_OIDC_* variables
+// to OidcProviders under DefaultOidcProviderKey. Provider from config file takes precedence.
+func loadOidcEnvironmentToObject(conf *ConfigType) error {
+	if _, exists := conf.OidcProviders[DefaultOidcProviderKey]; exists {
+		return nil
+	}
+
+	var provider OidcProvider
+	if err := loadEnvironmentToObject(&provider); err != nil {
+		return err
+	}
+
+	if provider.ClientID == "" {
+		return nil
+	}
+
+	if conf.OidcProviders == nil {
+		conf.OidcProviders = make(map[string]OidcProvider)
+	}
+	conf.OidcProviders[DefaultOidcProviderKey] = provider
+
+	return nil
+}
+
+// dbEnvDenylist returns database configs not used by the dialect set in
+// SEMAPHORE_DB_DIALECT or config, so SEMAPHORE_DB_* variables don't populate them.
+// Nothing is denied if dialect is not set, because it is detected by populated config.
+// SEMAPHORE_DB_DIALECT is ignored if Dialect is in denylist.
+func dbEnvDenylist(conf *ConfigType, denylist []string) []string {
+	dialect := conf.Dialect
+	if envDialect := os.Getenv("SEMAPHORE_DB_DIALECT"); envDialect != "" && !isFieldPathDenied("Dialect", denylist) {
+		dialect = envDialect
+	}
+
+	dialectConfigs := map[string]string{
+		DbDriverMySQL:    "MySQL",
+		DbDriverBolt:     "BoltDb",
+		DbDriverPostgres: "Postgres",
+	}
+
+	if _, ok := dialectConfigs[dialect]; !ok {
+		return nil
+	}
+
+	var unused []string
+	for _, name := range []string{"MySQL", "BoltDb", "Postgres"} {
+		if name != dialectConfigs[dialect] {
+			unused = append(unused, name)
+		}
+	}
+
+	return unused
+}
+
+// loadDbEnvironmentToObject loads database environment variables which can't be
+// mapped by env tags: SEMAPHORE_DB_PATH sets path of BoltDB file,
+// SEMAPHORE_DB_NAME sets database name and SEMAPHORE_DB_PORT sets port
+// of the database of the active dialect.
+func loadDbEnvironmentToObject(conf *ConfigType, denylist []string) error {
+	if dbPath, exists := os.LookupEnv("SEMAPHORE_DB_PATH"); exists && !isFieldPathDenied("BoltDb.Hostname", denylist) {
+		conf.BoltDb.Hostname = dbPath
+	}
+
+	if dbName := os.Getenv("SEMAPHORE_DB_NAME"); dbName != "" {
+		if !isFieldPathDenied("MySQL.DbName", denylist) {
+			conf.MySQL.DbName = dbName
+		}
+		if !isFieldPathDenied("Postgres.DbName", denylist) {
+			conf.Postgres.DbName = dbName
+		}
+	}
+
+	dbPort, exists := os.LookupEnv("SEMAPHORE_DB_PORT")
+	if !exists || dbPort == "" {
+		return nil
+	}
+
+	port, err := strconv.Atoi(dbPort)
+	if err != nil {
+		return fmt.Errorf("value of SEMAPHORE_DB_PORT is not valid: %v", dbPort)
+	}
+
+	dialect, err := conf.GetDialect()
+	if err != nil {
+		return nil
+	}
+
+	switch dialect {
+	case DbDriverMySQL:
+		if !isFieldPathDenied("MySQL.Port", denylist) {
+			conf.MySQL.Port = port
+		}
+	case DbDriverPostgres:
+		if !isFieldPathDenied("Postgres.Port", denylist) {
+			conf.Postgres.Port = port
+		}
+	}
+
+	return nil
+}
+
+func exitOnConfigError(msg string) {
+	fmt.Println(msg)
+	os.Exit(1)
+}
+
+func exitOnConfigFileError(err error) {
+	if err != nil {
+		exitOnConfigError("Cannot Find configuration! Use --config parameter to point to a JSON file generated by `semaphore setup`.")
+	}
+}
+
+func decodeConfig(file io.Reader, format string) error {
+	return decodeConfigObject(file, format, &Config)
+}
+
+func mapToQueryString(m map[string]string) (str string) {
+	options := make([]string, 0, len(m))
+	for option := range m {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+
+	for _, option := range options {
+		if str != "" {
+			str += "&"
+		}
+		str += option + "=" + url.QueryEscape(m[option])
+	}
+	if str != "" {
+		str = "?" + str
+	}
+	return
+}
+
+// FindSemaphore looks in the PATH for the semaphore variable
+// if not found it will attempt to find the absolute path of the first
+// os argument, the semaphore command, and return it
+func FindSemaphore() string {
+	cmdPath, _ := exec.LookPath("semaphore") //nolint: gas
+
+	if len(cmdPath) == 0 {
+		cmdPath, _ = filepath.Abs(os.Args[0]) // nolint: gas
+	}
+
+	return cmdPath
+}
+
+func AnsibleVersion() string {
+	bytes, err := exec.Command("ansible", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
+// UpdateCheckResult is a result of CheckUpdate
+type UpdateCheckResult struct {
+	// Available is true if Release is newer than the running version
+	Available bool
+	Release   *github.RepositoryRelease
+	// Reachable is false if GitHub was not reached, Error contains the reason
+	Reachable bool
+	// Offline is true if update check is disabled by OfflineMode
+	Offline bool
+	Error   error
+}
+
+// CheckUpdate uses the GitHub client to check for new tags in the semaphore repo
+func CheckUpdate() (res UpdateCheckResult) {
+	if Config != nil && Config.OfflineMode {
+		res.Offline = true
+		res.Error = errors.New("update check is disabled in offline mode")
+		return
+	}
+
+	// fetch releases
+	gh := github.NewClient(BuildHTTPClient(nil))
+	gh.UserAgent = Config.GetUserAgent()
+	releases, _, err := gh.Repositories.ListReleases(context.TODO(), "ansible-semaphore", "semaphore", nil)
+	if err != nil {
+		res.Error = err
+		return
+	}
+
+	res.Reachable = true
+
+	if len(releases) == 0 || releases[0].TagName == nil {
+		return
+	}
+
+	res.Release = releases[0]
+	res.Available = strings.TrimPrefix(*releases[0].TagName, "v") != Version
+
+	return
+}
+
+func (d *DbConfig) IsPresent() bool {
+	return d.GetHostname() != ""
+}
+
+func (d *DbConfig) HasSupportMultipleDatabases() bool {
+	return true
+}
+
+// GetDbName, GetUsername, GetPassword and GetHostname return loaded values.
+// SEMAPHORE_DB_* variables are applied while loading the config, so
+// EnvOverrideDenylist and ConfigPrecedence are respected.
+func (d *DbConfig) GetDbName() string {
+	return d.DbName
+}
+
+func (d *DbConfig) GetUsername() string {
+	return d.Username
+}
+
+func (d *DbConfig) GetPassword() string {
+	return d.Password
+}
+
+func (d *DbConfig) GetHostname() string {
+	return d.Hostname
+}
+
+func (d *DbConfig) GetPort() int {
+	return d.Port
+}
+
+// GetAddress returns host:port of MySQL or Postgres server.
+// Port of the hostname takes precedence over Port field for backward compatibility.
+func (d *DbConfig) GetAddress() string {
+	host := d.GetHostname()
+	port := d.GetPort()
+
+	if port == 0 || d.IsUnixSocket() {
+		return host
+	}
+
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port))
+}
+
+// IsUnixSocket returns true if MySQL or Postgres hostname is an absolute path of Unix socket
+func (d *DbConfig) IsUnixSocket() bool {
+	return d.Dialect != DbDriverBolt && strings.HasPrefix(d.GetHostname(), "/")
+}
+
+// postgresSocket returns directory and port of Postgres socket. Postgres driver expects
+// directory and builds socket file name from the port, so path to .s.PGSQL.<port>
+// is split into directory and port. Port is empty if path is a directory.
+func postgresSocket(socketPath string) (dir string, port string) {
+	if name := filepath.Base(socketPath); strings.HasPrefix(name, ".s.PGSQL.") {
+		return filepath.Dir(socketPath), strings.TrimPrefix(name, ".s.PGSQL.")
+	}
+	return socketPath, ""
+}
+
+func (d *DbConfig) GetConnectionString(includeDbName bool) (connectionString string, err error) {
+	dbName := d.GetDbName()
+	dbUser := d.GetUsername()
+	dbPass := d.GetPassword()
+	dbHost := d.GetHostname()
+	dbAddress := d.GetAddress()
+
+	switch d.Dialect {
+	case DbDriverBolt:
+		connectionString = dbHost
+	case DbDriverMySQL:
+		protocol := "tcp"
+		if d.IsUnixSocket() {
+			protocol = "unix"
+		}
+		if includeDbName {
+			connectionString = fmt.Sprintf(
+				"%s:%s@%s(%s)/%s",
+				dbUser,
+				dbPass,
+				protocol,
+				dbAddress,
+				dbName)
+		} else {
+			connectionString = fmt.Sprintf(
+				"%s:%s@%s(%s)/",
+				dbUser,
+				dbPass,
+				protocol,
+				dbAddress)
+		}
+		options := map[string]string{
+			"parseTime":         "true",
+			"interpolateParams": "true",
+		}
+		if d.StatementTimeoutSeconds > 0 {
+			options["max_execution_time"] = strconv.Itoa(d.StatementTimeoutSeconds * 1000)
+		}
+		for v, k := range d.Options {
+			options[v] = k
+		}
+		var tlsParam string
+		tlsParam, err = d.mysqlTLSParam()
+		if err != nil {
+			return
+		}
+		if tlsParam != "" {
+			options["tls"] = tlsParam
+		}
+		if d.RequireTLS {
+			switch options["tls"] {
+			case "", "false", "preferred":
+				options["tls"] = "true"
+			}
+		}
+		connectionString += mapToQueryString(options)
+	case DbDriverPostgres:
+		// url.URL escapes all characters of the password which break userinfo, like @, : and /
+		dsn := url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(dbUser, dbPass),
+			Host:   dbAddress,
+		}
+		if includeDbName {
+			dsn.Path = "/" + dbName
+		}
+		options := make(map[string]string)
+		if d.IsUnixSocket() {
+			// socket is passed as host parameter, URL host must be empty
+			dsn.Host = ""
+			socketDir, socketPort := postgresSocket(dbHost)
+			options["host"] = socketDir
+			if socketPort == "" && d.GetPort() != 0 {
+				socketPort = strconv.Itoa(d.GetPort())
+			}
+			if socketPort != "" {
+				options["port"] = socketPort
+			}
+		}
+		connectionString = dsn.String()
+		if d.StatementTimeoutSeconds > 0 {
+			options["statement_timeout"] = strconv.Itoa(d.StatementTimeoutSeconds * 1000)
+		}
+		if d.ApplicationName != "" {
+			options["application_name"] = d.ApplicationName
+		}
+		for v, k := range d.Options {
+			options[v] = k
+		}
+		for option, value := range map[string]string{
+			"sslmode":     d.SslMode,
+			"sslrootcert": d.SslRootCert,
+			"sslcert":     d.SslCert,
+			"sslkey":      d.SslKey,
+		} {
+			if value != "" {
+				options[option] = value
+			}
+		}
+		if d.RequireTLS {
+			switch options["sslmode"] {
+			case "", "disable", "allow", "prefer":
+				options["sslmode"] = "require"
+			}
+		}
+		connectionString += mapToQueryString(options)
+	default:
+		err = fmt.Errorf("unsupported database driver: %s", d.Dialect)
+	}
+	return
+}
+
+// DbPoolSettings are limits applied to the opened sql.DB
+type DbPoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// GetPoolSettings returns connection pool settings as typed values
+func (d *DbConfig) GetPoolSettings() (settings DbPoolSettings, err error) {
+	settings.MaxOpenConns = d.MaxOpenConns
+	settings.MaxIdleConns = d.MaxIdleConns
+
+	if d.ConnMaxLifetime != "" {
+		settings.ConnMaxLifetime, err = time.ParseDuration(d.ConnMaxLifetime)
+	}
+
+	return
+}
+
+// TLS modes of MySQL connection
+const (
+	DbTLSModeDisabled   = "disabled"
+	DbTLSModeSkipVerify = "skip-verify"
+	DbTLSModeVerifyFull = "verify-full"
+)
+
+// mysqlTLSConfigName is name of the custom TLS config registered in MySQL driver
+const mysqlTLSConfigName = "semaphore"
+
+// mysqlTLSParam returns value of "tls" parameter of MySQL DSN.
+// If CA or client certificate is set, it registers custom TLS config in the driver.
+func (d *DbConfig) mysqlTLSParam() (string, error) {
+	switch d.TLSMode {
+	case "":
+		return "", nil
+	case DbTLSModeDisabled:
+		return "false", nil
+	case DbTLSModeSkipVerify, DbTLSModeVerifyFull:
+	default:
+		return "", fmt.Errorf("unsupported MySQL TLS mode: %s", d.TLSMode)
+	}
+
+	if d.TLSCA == "" && d.TLSCert == "" {
+		if d.TLSMode == DbTLSModeSkipVerify {
+			return "skip-verify", nil
+		}
+		return "true", nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: d.TLSMode == DbTLSModeSkipVerify, //nolint:gosec
+	}
+
+	tlsConfig.ServerName = d.GetHostname()
+	if host, _, err := net.SplitHostPort(tlsConfig.ServerName); err == nil {
+		tlsConfig.ServerName = host
+	}
+
+	if d.TLSCA != "" {
+		ca, err := os.ReadFile(d.TLSCA)
+		if err != nil {
+			return "", err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return "", fmt.Errorf("no certificates found in %s", d.TLSCA)
+		}
+	}
+
+	if d.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(d.TLSCert, d.TLSKey)
+		if err != nil {
+			return "", err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if err := mysql.RegisterTLSConfig(mysqlTLSConfigName, tlsConfig); err != nil {
+		return "", err
+	}
+
+	return mysqlTLSConfigName, nil
+}
+
+func (conf *ConfigType) PrintDbInfo() {
+	dialect, err := conf.GetDialect()
+	if err != nil {
+		panic(err)
+	}
+	switch dialect {
+	case DbDriverMySQL:
+		fmt.Printf("MySQL %v@%v %v\n", conf.MySQL.GetUsername(), conf.MySQL.GetHostname(), conf.MySQL.GetDbName())
+	case DbDriverBolt:
+		fmt.Printf("BoltDB %v\n", conf.BoltDb.GetHostname())
+	case DbDriverPostgres:
+		fmt.Printf("Postgres %v@%v %v\n", conf.Postgres.GetUsername(), conf.Postgres.GetHostname(), conf.Postgres.GetDbName())
+	default:
+		panic(fmt.Errorf("database configuration not found"))
+	}
+}
+
+func (conf *ConfigType) GetDialect() (dialect string, err error) {
+	if conf.Dialect == "" {
+		switch {
+		case conf.MySQL.IsPresent():
+			dialect = DbDriverMySQL
+		case conf.BoltDb.IsPresent():
+			dialect = DbDriverBolt
+		case conf.Postgres.IsPresent():
+			dialect = DbDriverPostgres
+		default:
+			err = errors.New("database configuration not found")
+		}
+		return
+	}
+
+	dialect = conf.Dialect
+	return
+}
+
+func (conf *ConfigType) GetDBConfig() (dbConfig DbConfig, err error) {
+	var dialect string
+	dialect, err = conf.GetDialect()
+
+	if err != nil {
+		return
+	}
+
+	switch dialect {
+	case DbDriverBolt:
+		dbConfig = conf.BoltDb
+	case DbDriverPostgres:
+		dbConfig = conf.Postgres
+	case DbDriverMySQL:
+		dbConfig = conf.MySQL
+	default:
+		err = errors.New("database configuration not found")
+	}
+
+	dbConfig.Dialect = dialect
+	dbConfig.RequireTLS = conf.RequireDbTLS
+
+	return
+}
+
+// GetEmailConfig returns settings of email alerting
+func (conf *ConfigType) GetEmailConfig() EmailConfig {
+	poolSize := conf.EmailPoolSize
+	if poolSize < 1 {
+		poolSize = 1
+	}
+
+	return EmailConfig{
+		Alert:     conf.EmailAlert,
+		Sender:    conf.EmailSender,
+		Host:      conf.EmailHost,
+		Port:      conf.EmailPort,
+		Username:  conf.EmailUsername,
+		Password:  conf.EmailPassword,
+		Secure:    conf.EmailSecure,
+		PoolSize:  poolSize,
+		KeepAlive: time.Duration(conf.EmailKeepAliveSeconds) * time.Second,
+	}
+}
+
+// newCookieCodec creates codec from base64 encoded keys
+func (conf *ConfigType) newCookieCodec(hashKey string, encryptionKey string) *securecookie.SecureCookie {
+	var encryption []byte
+
+	hash, _ := base64.StdEncoding.DecodeString(hashKey)
+	if len(encryptionKey) > 0 {
+		encryption, _ = base64.StdEncoding.DecodeString(encryptionKey)
+	}
+
+	codec := securecookie.New(hash, encryption)
+
+	if conf.CookieMaxLength > 0 {
+		codec.MaxLength(conf.CookieMaxLength)
+	}
+
+	if conf.CookieSerializer == "json" {
+		codec.SetSerializer(securecookie.JSONEncoder{})
+	}
+
+	return codec
+}
+
+// splitCookieVerifyKey splits `hash:encryption` pair
+func splitCookieVerifyKey(key string) (hash string, encryption string) {
+	parts := strings.SplitN(key, ":", 2)
+	hash = parts[0]
+	if len(parts) > 1 {
+		encryption = parts[1]
+	}
+	return
+}
+
+// GetAccessKeyEncryptionKeys returns decoded access key encryption keys
+// in priority order: AccessKeyEncryption first, then AccessKeyEncryptionOld.
+// Access keys are encrypted only by the first key. Keys which are not valid
+// base64 are skipped, they are rejected by validateSecretKeys.
+func (conf *ConfigType) GetAccessKeyEncryptionKeys() [][]byte {
+	var keys [][]byte
+
+	for _, encoded := range append([]string{conf.AccessKeyEncryption}, conf.AccessKeyEncryptionOld...) {
+		if encoded == "" {
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// GetCookieCodecs returns codecs for decoding cookies in priority order:
+// current keys first, then CookieVerifyKeys.
+func (conf *ConfigType) GetCookieCodecs() []securecookie.Codec {
+	codecs := []securecookie.Codec{
+		conf.newCookieCodec(conf.CookieHash, conf.CookieEncryption),
+	}
+
+	for _, key := range conf.CookieVerifyKeys {
+		codecs = append(codecs, conf.newCookieCodec(splitCookieVerifyKey(key)))
+	}
+
+	return codecs
+}
+
+// GetSlackConfig returns settings of Slack alerting
+// or error if alerting is enabled but not fully configured.
+func (conf *ConfigType) GetSlackConfig() (SlackConfig, error) {
+	res := SlackConfig{
+		Alert: conf.SlackAlert,
+		Urls:  conf.GetSlackUrls(),
+	}
+	return res, res.Validate()
+}
+
+// GetDiscordConfig returns settings of Discord alerting
+// or error if alerting is enabled but not fully configured.
+func (conf *ConfigType) GetDiscordConfig() (DiscordConfig, error) {
+	res := DiscordConfig{
+		Alert: conf.DiscordAlert,
+		Url:   conf.DiscordUrl,
+	}
+	return res, res.Validate()
+}
+
+// GetMsTeamsConfig returns settings of Microsoft Teams alerting
+// or error if alerting is enabled but not fully configured.
+func (conf *ConfigType) GetMsTeamsConfig() (MsTeamsConfig, error) {
+	res := MsTeamsConfig{
+		Alert: conf.MsTeamsAlert,
+		Url:   conf.MsTeamsUrl,
+	}
+	return res, res.Validate()
+}
+
+// GetWebhookConfig returns settings of generic webhook alerting
+// or error if alerting is enabled but not fully configured.
+func (conf *ConfigType) GetWebhookConfig() (WebhookConfig, error) {
+	res := WebhookConfig{
+		Alert:   conf.WebhookAlert,
+		Urls:    conf.GetWebhookUrls(),
+		Headers: conf.GetWebhookHeaders(),
 	}
+	return res, res.Validate()
+}
 
-	for i := 0; i < t.NumField(); i++ {
-		fieldType := t.Field(i)
-		fieldValue := v.Field(i)
+// GetWebhookUrls returns deduplicated webhooks from WebhookUrl and WebhookUrls
+func (conf *ConfigType) GetWebhookUrls() []string {
+	return uniqueNonEmpty(append([]string{conf.WebhookUrl}, conf.WebhookUrls...))
+}
 
-		if fieldType.Type.Kind() == reflect.Struct {
-			err := loadEnvironmentToObject(fieldValue.Addr().Interface())
-			if err != nil {
-				return err
-			}
-			continue
-		}
+// GetWebhookHeaders returns copy of headers added to webhook alert requests
+func (conf *ConfigType) GetWebhookHeaders() map[string]string {
+	headers := make(map[string]string, len(conf.WebhookHeaders))
+	for name, value := range conf.WebhookHeaders {
+		headers[name] = value
+	}
+	return headers
+}
 
-		envVar := fieldType.Tag.Get("env")
-		if envVar == "" {
-			continue
+// GetLdapServers returns deduplicated LDAP servers from LdapServer and LdapServers
+// in the order they must be tried
+func (conf *ConfigType) GetLdapServers() []string {
+	return uniqueNonEmpty(append([]string{conf.LdapServer}, conf.LdapServers...))
+}
+
+// GetSlackUrls returns deduplicated Slack webhooks from SlackUrl and SlackUrls
+func (conf *ConfigType) GetSlackUrls() []string {
+	return uniqueNonEmpty(append([]string{conf.SlackUrl}, conf.SlackUrls...))
+}
+
+// containsString returns true if items contain value
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
 		}
+	}
+	return false
+}
 
-		envValue, exists := os.LookupEnv(envVar)
+// uniqueNonEmpty returns non-empty items in order of their first occurrence
+func uniqueNonEmpty(items []string) []string {
+	res := make([]string, 0, len(items))
+	seen := make(map[string]bool)
 
-		if !exists {
+	for _, item := range items {
+		if item == "" || seen[item] {
 			continue
 		}
+		seen[item] = true
+		res = append(res, item)
+	}
+
+	return res
+}
+
+// GetTelegramConfig returns settings of Telegram alerting
+// or error if alerting is enabled but not fully configured.
+func (conf *ConfigType) GetTelegramConfig() (TelegramConfig, error) {
+	res := TelegramConfig{
+		Alert: conf.TelegramAlert,
+		Chat:  conf.TelegramChat,
+		Token: conf.TelegramToken,
+	}
+	return res, res.Validate()
+}
 
-		setConfigValue(fieldValue, envValue)
+// EffectiveListenURL returns URL the server listens on,
+// e.g. `http://0.0.0.0:3000` or `http://[::1]:3000`.
+func (conf *ConfigType) EffectiveListenURL() string {
+	host := conf.Interface
+	if host == "" {
+		host = "0.0.0.0"
 	}
 
-	return nil
+	u := url.URL{
+		Scheme: "http",
+		Host:   net.JoinHostPort(host, strings.TrimPrefix(conf.Port, ":")),
+	}
+
+	return u.String()
 }
 
-func loadConfigEnvironment() {
-	err := loadEnvironmentToObject(Config)
-	if err != nil {
-		panic(err)
+// BindAddress returns address the server listens on, e.g. `127.0.0.1:3000`.
+// Interface must be an IP address or empty, Port must be in range 1-65535.
+func (conf *ConfigType) BindAddress() (string, error) {
+	var problems []string
+
+	if conf.Interface != "" && net.ParseIP(conf.Interface) == nil {
+		problems = append(problems, fmt.Sprintf("interface '%v' is not an IP address", conf.Interface))
+	}
+
+	port, err := strconv.Atoi(strings.TrimPrefix(conf.Port, ":"))
+	if err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("port '%v' is not in range 1-65535", conf.Port))
+	}
+
+	if len(problems) > 0 {
+		return "", fmt.Errorf("bind address is not valid: %v", strings.Join(problems, ", "))
 	}
+
+	return net.JoinHostPort(conf.Interface, strconv.Itoa(port)), nil
 }
 
-func exitOnConfigError(msg string) {
-	fmt.Println(msg)
-	os.Exit(1)
+func validateBindAddress(conf *ConfigType) error {
+	_, err := conf.BindAddress()
+	return err
 }
 
-func exitOnConfigFileError(err error) {
-	if err != nil {
-		exitOnConfigError("Cannot Find configuration! Use --config parameter to point to a JSON file generated by `semaphore setup`.")
+// ExternalURL returns public URL of the server taken from WebHost
+// or empty string if WebHost is not set.
+func (conf *ConfigType) ExternalURL() string {
+	return strings.TrimSuffix(conf.WebHost, "/")
+}
+
+// defaultCookieIdleTimeout is used when CookieIdleTimeoutSeconds is not set
+const defaultCookieIdleTimeout = 7 * 24 * time.Hour
+
+// GetCookieIdleTimeout returns time after which an unused session expires
+func (conf *ConfigType) GetCookieIdleTimeout() time.Duration {
+	if conf.CookieIdleTimeoutSeconds <= 0 {
+		return defaultCookieIdleTimeout
 	}
+	return time.Duration(conf.CookieIdleTimeoutSeconds) * time.Second
 }
 
-func decodeConfig(file io.Reader) {
-	if err := json.NewDecoder(file).Decode(&Config); err != nil {
-		fmt.Println("Could not decode configuration!")
-		panic(err)
+// GetLoginBanner returns title and text of the banner shown on the login page
+func (conf *ConfigType) GetLoginBanner() (title string, text string) {
+	return conf.LoginBannerTitle, conf.LoginBannerText
+}
+
+// hostPortWithDefault adds default port to the host if it has no port
+func hostPortWithDefault(host string, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
 	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
 }
 
-func mapToQueryString(m map[string]string) (str string) {
-	for option, value := range m {
-		if str != "" {
-			str += "&"
-		}
-		str += option + "=" + value
+// urlHostPort returns host:port of the URL or empty string if URL is not valid
+func urlHostPort(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
 	}
-	if str != "" {
-		str = "?" + str
+
+	port := u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "ldap":
+			port = "389"
+		case "ldaps":
+			port = "636"
+		default:
+			port = "443"
+		}
 	}
-	return
+
+	return net.JoinHostPort(u.Hostname(), port)
 }
 
-// FindSemaphore looks in the PATH for the semaphore variable
-// if not found it will attempt to find the absolute path of the first
-// os argument, the semaphore command, and return it
-func FindSemaphore() string {
-	cmdPath, _ := exec.LookPath("semaphore") //nolint: gas
+// RequiredEgressEndpoints returns host:port pairs the server connects to
+// according to the config. Useful for firewall allowlisting.
+func (conf *ConfigType) RequiredEgressEndpoints() []string {
+	endpoints := make(map[string]bool)
 
-	if len(cmdPath) == 0 {
-		cmdPath, _ = filepath.Abs(os.Args[0]) // nolint: gas
+	add := func(endpoint string) {
+		if endpoint != "" {
+			endpoints[endpoint] = true
+		}
 	}
 
-	return cmdPath
+	if dbConfig, err := conf.GetDBConfig(); err == nil && !dbConfig.IsUnixSocket() {
+		switch dbConfig.Dialect {
+		case DbDriverMySQL:
+			add(hostPortWithDefault(dbConfig.GetAddress(), "3306"))
+		case DbDriverPostgres:
+			add(hostPortWithDefault(dbConfig.GetAddress(), "5432"))
+		}
+	}
+
+	if len(conf.Vault.Secrets) > 0 {
+		add(urlHostPort(conf.Vault.Address))
+	}
+
+	if usesAWSSecrets(conf) {
+		add(awsSecretsManagerEndpoint())
+	}
+
+	if conf.LdapEnable {
+		for _, server := range conf.GetLdapServers() {
+			if conf.LdapNeedTLS {
+				add(hostPortWithDefault(server, "636"))
+			} else {
+				add(hostPortWithDefault(server, "389"))
+			}
+		}
+	}
+
+	for _, provider := range conf.OidcProviders {
+		add(urlHostPort(provider.AutoDiscovery))
+		add(urlHostPort(provider.Endpoint.IssuerURL))
+		add(urlHostPort(provider.Endpoint.AuthURL))
+		add(urlHostPort(provider.Endpoint.TokenURL))
+		add(urlHostPort(provider.Endpoint.UserInfoURL))
+		add(urlHostPort(provider.Endpoint.JWKSURL))
+	}
+
+	if conf.EmailAlert && conf.EmailHost != "" {
+		port := conf.EmailPort
+		if port == "" {
+			port = "25"
+		}
+		add(net.JoinHostPort(conf.EmailHost, port))
+	}
+
+	if conf.AlertUrlProxy != "" && (conf.TelegramAlert || conf.SlackAlert || conf.DiscordAlert || conf.MsTeamsAlert || conf.WebhookAlert) {
+		add(urlHostPort(conf.AlertUrlProxy))
+	} else {
+		if conf.TelegramAlert {
+			add("api.telegram.org:443")
+		}
+		if conf.SlackAlert {
+			for _, slackUrl := range conf.GetSlackUrls() {
+				add(urlHostPort(slackUrl))
+			}
+		}
+		if conf.DiscordAlert && conf.DiscordUrl != "" {
+			add(urlHostPort(conf.DiscordUrl))
+		}
+		if conf.MsTeamsAlert && conf.MsTeamsUrl != "" {
+			add(urlHostPort(conf.MsTeamsUrl))
+		}
+		if conf.WebhookAlert {
+			for _, webhookUrl := range conf.GetWebhookUrls() {
+				add(urlHostPort(webhookUrl))
+			}
+		}
+	}
+
+	// update check
+	if !conf.OfflineMode {
+		add("api.github.com:443")
+	}
+
+	res := make([]string, 0, len(endpoints))
+	for endpoint := range endpoints {
+		res = append(res, endpoint)
+	}
+	sort.Strings(res)
+
+	return res
 }
 
-func AnsibleVersion() string {
-	bytes, err := exec.Command("ansible", "--version").Output()
+// GetUserAgent returns value of User-Agent header for outbound requests
+func (conf *ConfigType) GetUserAgent() string {
+	if conf == nil || conf.UserAgent == "" {
+		return "Semaphore/" + Version
+	}
+	return conf.UserAgent
+}
+
+// GetInstanceName returns name of the instance used to tag outbound alerts
+func (conf *ConfigType) GetInstanceName() string {
+	if conf.InstanceName != "" {
+		return conf.InstanceName
+	}
+
+	hostname, err := os.Hostname()
 	if err != nil {
 		return ""
 	}
-	return string(bytes)
+	return hostname
 }
 
-// CheckUpdate uses the GitHub client to check for new tags in the semaphore repo
-func CheckUpdate() (updateAvailable *github.RepositoryRelease, err error) {
-	// fetch releases
-	gh := github.NewClient(nil)
-	releases, _, err := gh.Repositories.ListReleases(context.TODO(), "ansible-semaphore", "semaphore", nil)
-	if err != nil {
-		return
+// GetAlertRetryCount returns number of retries for failed alert delivery
+func (conf *ConfigType) GetAlertRetryCount() int {
+	if conf.AlertRetryCount < 0 {
+		return 0
 	}
+	return conf.AlertRetryCount
+}
+
+// GetAlertRetryDelay returns delay before the first retry of failed alert delivery
+func (conf *ConfigType) GetAlertRetryDelay() time.Duration {
+	return time.Duration(conf.AlertRetryDelaySeconds) * time.Second
+}
 
-	updateAvailable = nil
-	if (*releases[0].TagName)[1:] != Version {
-		updateAvailable = releases[0]
+// GetOidcAccountLinking returns how OIDC identities are linked to existing accounts
+func (conf *ConfigType) GetOidcAccountLinking() string {
+	if conf.OidcAccountLinking == "" {
+		return OidcAccountLinkingNone
 	}
+	return conf.OidcAccountLinking
+}
 
-	return
+// UseAnsibleExecutionEnvironment returns true if playbooks must be run in a container
+func (conf *ConfigType) UseAnsibleExecutionEnvironment() bool {
+	return conf.AnsibleExecutionEnvironment != ""
 }
 
-func (d *DbConfig) IsPresent() bool {
-	return d.GetHostname() != ""
+// GetAnsibleExecutionEnvironment returns container engine and image of the execution environment
+func (conf *ConfigType) GetAnsibleExecutionEnvironment() (engine string, image string) {
+	return conf.AnsibleExecutionEnvironment, conf.AnsibleRunnerImage
 }
 
-func (d *DbConfig) HasSupportMultipleDatabases() bool {
-	return true
+// GetDbQueryLogging returns true if SQL queries must be logged and minimal
+// duration of logged queries. Zero duration means all queries are logged.
+func (conf *ConfigType) GetDbQueryLogging() (enabled bool, slowThreshold time.Duration) {
+	return conf.DbLogQueries, time.Duration(conf.DbSlowQueryThresholdMs) * time.Millisecond
 }
 
-func (d *DbConfig) GetDbName() string {
-	dbName := os.Getenv("SEMAPHORE_DB_NAME")
-	if dbName != "" {
-		return dbName
+// shortAnsibleModuleName removes collection of builtin modules,
+// e.g. ansible.builtin.shell -> shell
+func shortAnsibleModuleName(name string) string {
+	for _, prefix := range []string{"ansible.builtin.", "ansible.legacy."} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
 	}
-	return d.DbName
+	return name
 }
 
-func (d *DbConfig) GetUsername() string {
-	username := os.Getenv("SEMAPHORE_DB_USER")
-	if username != "" {
-		return username
+// IsModuleAllowed returns false if the Ansible module is listed in AnsibleModuleDenylist
+func (conf *ConfigType) IsModuleAllowed(name string) bool {
+	name = shortAnsibleModuleName(strings.ToLower(strings.TrimSpace(name)))
+
+	for _, denied := range conf.AnsibleModuleDenylist {
+		if shortAnsibleModuleName(denied) == name {
+			return false
+		}
 	}
-	return d.Username
+
+	return true
 }
 
-func (d *DbConfig) GetPassword() string {
-	password := os.Getenv("SEMAPHORE_DB_PASS")
-	if password != "" {
-		return password
+// GetAnsiblePluginFilters returns content of Ansible plugin filters file
+// (ANSIBLE_PLUGIN_FILTERS_CFG) which rejects denied modules, or empty string
+// if no modules are denied.
+func (conf *ConfigType) GetAnsiblePluginFilters() string {
+	if len(conf.AnsibleModuleDenylist) == 0 {
+		return ""
 	}
-	return d.Password
-}
 
-func (d *DbConfig) GetHostname() string {
-	hostname := os.Getenv("SEMAPHORE_DB_HOST")
-	if hostname != "" {
-		return hostname
+	var modules strings.Builder
+	for _, module := range conf.AnsibleModuleDenylist {
+		modules.WriteString("  - " + shortAnsibleModuleName(module) + "\n")
 	}
-	return d.Hostname
+
+	// module_blacklist is used by Ansible older than 2.11
+	return "---\nfilter_version: '1.0'\nmodule_rejectlist:\n" + modules.String() +
+		"module_blacklist:\n" + modules.String()
 }
 
-func (d *DbConfig) GetConnectionString(includeDbName bool) (connectionString string, err error) {
-	dbName := d.GetDbName()
-	dbUser := d.GetUsername()
-	dbPass := d.GetPassword()
-	dbHost := d.GetHostname()
+// GetMaxTaskHistoryPerTemplate returns number of tasks kept for each template
+// and false if history is unlimited.
+func (conf *ConfigType) GetMaxTaskHistoryPerTemplate() (int, bool) {
+	return conf.MaxTaskHistoryPerTemplate, conf.MaxTaskHistoryPerTemplate > 0
+}
 
-	switch d.Dialect {
-	case DbDriverBolt:
-		connectionString = dbHost
-	case DbDriverMySQL:
-		if includeDbName {
-			connectionString = fmt.Sprintf(
-				"%s:%s@tcp(%s)/%s",
-				dbUser,
-				dbPass,
-				dbHost,
-				dbName)
-		} else {
-			connectionString = fmt.Sprintf(
-				"%s:%s@tcp(%s)/",
-				dbUser,
-				dbPass,
-				dbHost)
-		}
-		options := map[string]string{
-			"parseTime":         "true",
-			"interpolateParams": "true",
-		}
-		for v, k := range d.Options {
-			options[v] = k
-		}
-		connectionString += mapToQueryString(options)
-	case DbDriverPostgres:
-		if includeDbName {
-			connectionString = fmt.Sprintf(
-				"postgres://%s:%s@%s/%s",
-				dbUser,
-				url.QueryEscape(dbPass),
-				dbHost,
-				dbName)
-		} else {
-			connectionString = fmt.Sprintf(
-				"postgres://%s:%s@%s",
-				dbUser,
-				url.QueryEscape(dbPass),
-				dbHost)
-		}
-		connectionString += mapToQueryString(d.Options)
-	default:
-		err = fmt.Errorf("unsupported database driver: %s", d.Dialect)
+// GetConfigPrecedence returns which source wins if a field is set
+// both in the config file and in the environment
+func (conf *ConfigType) GetConfigPrecedence() string {
+	if conf.ConfigPrecedence == "" {
+		return ConfigPrecedenceEnv
 	}
-	return
+	return conf.ConfigPrecedence
 }
 
-func (conf *ConfigType) PrintDbInfo() {
-	dialect, err := conf.GetDialect()
-	if err != nil {
-		panic(err)
-	}
-	switch dialect {
-	case DbDriverMySQL:
-		fmt.Printf("MySQL %v@%v %v\n", conf.MySQL.GetUsername(), conf.MySQL.GetHostname(), conf.MySQL.GetDbName())
-	case DbDriverBolt:
-		fmt.Printf("BoltDB %v\n", conf.BoltDb.GetHostname())
-	case DbDriverPostgres:
-		fmt.Printf("Postgres %v@%v %v\n", conf.Postgres.GetUsername(), conf.Postgres.GetHostname(), conf.Postgres.GetDbName())
-	default:
-		panic(fmt.Errorf("database configuration not found"))
+// GetTaskOverflowPolicy returns what happens with new tasks when MaxParallelTasks is reached
+func (conf *ConfigType) GetTaskOverflowPolicy() string {
+	if conf.TaskOverflowPolicy == "" {
+		return TaskOverflowQueue
 	}
+	return conf.TaskOverflowPolicy
 }
 
-func (conf *ConfigType) GetDialect() (dialect string, err error) {
-	if conf.Dialect == "" {
-		switch {
-		case conf.MySQL.IsPresent():
-			dialect = DbDriverMySQL
-		case conf.BoltDb.IsPresent():
-			dialect = DbDriverBolt
-		case conf.Postgres.IsPresent():
-			dialect = DbDriverPostgres
-		default:
-			err = errors.New("database configuration not found")
-		}
-		return
-	}
+// GetOidcJWKSCacheTTL returns how long OIDC signing keys are cached
+func (conf *ConfigType) GetOidcJWKSCacheTTL() time.Duration {
+	return time.Duration(conf.OidcJWKSCacheTTLSeconds) * time.Second
+}
 
-	dialect = conf.Dialect
-	return
+// GetMaxConcurrentAuth returns maximum number of in-flight LDAP/OIDC
+// authentications. 0 means unlimited.
+func (conf *ConfigType) GetMaxConcurrentAuth() int {
+	if conf.MaxConcurrentAuth < 0 {
+		return 0
+	}
+	return conf.MaxConcurrentAuth
 }
 
-func (conf *ConfigType) GetDBConfig() (dbConfig DbConfig, err error) {
-	var dialect string
-	dialect, err = conf.GetDialect()
+// GetGitClientForProject returns Git client which should be used for the project.
+// Falls back to the global GitClientId.
+func (conf *ConfigType) GetGitClientForProject(projectID int) GitClientId {
+	if client, ok := conf.ProjectGitClient[projectID]; ok && client != "" {
+		return client
+	}
+	return GitClientId(conf.GitClientId)
+}
 
-	if err != nil {
-		return
+// IsEmailDomainAllowed checks whether a user with the given email
+// may be auto-provisioned according to AllowedEmailDomains.
+func (conf *ConfigType) IsEmailDomainAllowed(email string) bool {
+	if len(conf.AllowedEmailDomains) == 0 {
+		return true
 	}
 
-	switch dialect {
-	case DbDriverBolt:
-		dbConfig = conf.BoltDb
-	case DbDriverPostgres:
-		dbConfig = conf.Postgres
-	case DbDriverMySQL:
-		dbConfig = conf.MySQL
-	default:
-		err = errors.New("database configuration not found")
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
 	}
 
-	dbConfig.Dialect = dialect
+	domain := email[at+1:]
+	for _, allowed := range conf.AllowedEmailDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
 
-	return
+	return false
 }
 
 // GenerateSecrets generates cookie secret during setup
 func (conf *ConfigType) GenerateSecrets() {
-	hash := securecookie.GenerateRandomKey(32)
-	encryption := securecookie.GenerateRandomKey(32)
-	accessKeyEncryption := securecookie.GenerateRandomKey(32)
+	_ = conf.GenerateSecretsWithSize(32, 32)
+}
+
+// GenerateSecretsWithSize generates secrets with the given sizes in bytes.
+// Hash size must be 32 or 64, encryption size 16, 24 or 32 (AES-128/192/256)
+// and is used for both CookieEncryption and AccessKeyEncryption.
+func (conf *ConfigType) GenerateSecretsWithSize(hashBytes int, encBytes int) error {
+	if !containsInt(secretKeyLengths[0].lengths, hashBytes) {
+		return fmt.Errorf("hash key size %d is not valid, must be one of %v", hashBytes, secretKeyLengths[0].lengths)
+	}
+
+	if !containsInt(secretKeyLengths[1].lengths, encBytes) {
+		return fmt.Errorf("encryption key size %d is not valid, must be one of %v", encBytes, secretKeyLengths[1].lengths)
+	}
+
+	hash := securecookie.GenerateRandomKey(hashBytes)
+	encryption := securecookie.GenerateRandomKey(encBytes)
+	accessKeyEncryption := securecookie.GenerateRandomKey(encBytes)
 
 	conf.CookieHash = base64.StdEncoding.EncodeToString(hash)
 	conf.CookieEncryption = base64.StdEncoding.EncodeToString(encryption)
 	conf.AccessKeyEncryption = base64.StdEncoding.EncodeToString(accessKeyEncryption)
+
+	return nil
+}
+
+// containsInt returns true if items contain value
+func containsInt(items []int, value int) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateMissingSecrets generates only secrets which are not set, so existing
+// sessions and encrypted access keys stay valid when setup is run again.
+func (conf *ConfigType) GenerateMissingSecrets() {
+	secrets := []*string{&conf.CookieHash, &conf.CookieEncryption, &conf.AccessKeyEncryption}
+
+	for _, secret := range secrets {
+		if *secret == "" {
+			*secret = base64.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32))
+		}
+	}
 }
@@ -0,0 +1,128 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// awsSecretsManagerScheme marks values of sensitive fields which are
+// loaded from AWS Secrets Manager, e.g. `aws-sm://semaphore/db#password`.
+const awsSecretsManagerScheme = "aws-sm://"
+
+// awsSecretsManagerTimeout limits loading of all secrets referenced by the config
+const awsSecretsManagerTimeout = 30 * time.Second
+
+// secretsManagerClient is implemented by secretsmanager.Client
+type secretsManagerClient interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// newSecretsManagerClient creates client which uses the default AWS credential chain
+var newSecretsManagerClient = func(ctx context.Context) (secretsManagerClient, error) {
+	awsConf, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return secretsmanager.NewFromConfig(awsConf), nil
+}
+
+// resolveAWSSecretsToObject replaces `aws-sm://<secret-name>#<json-key>` values
+// of sensitive fields by values from AWS Secrets Manager. If json-key is omitted,
+// the whole secret string is used. Each secret is fetched once per call.
+func resolveAWSSecretsToObject(conf *ConfigType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), awsSecretsManagerTimeout)
+	defer cancel()
+
+	var client secretsManagerClient
+	secrets := make(map[string]string)
+	var resolveErr error
+
+	walkStringFields(conf, func(path string, get func() string, set func(string)) {
+		ref := get()
+		if resolveErr != nil || !strings.HasPrefix(ref, awsSecretsManagerScheme) || !isSecretConfigPath(path) {
+			return
+		}
+
+		name, key := strings.TrimPrefix(ref, awsSecretsManagerScheme), ""
+		if i := strings.LastIndex(name, "#"); i >= 0 {
+			name, key = name[:i], name[i+1:]
+		}
+
+		if client == nil {
+			if client, resolveErr = newSecretsManagerClient(ctx); resolveErr != nil {
+				resolveErr = fmt.Errorf("cannot create AWS Secrets Manager client: %v", resolveErr)
+				return
+			}
+		}
+
+		secret, ok := secrets[name]
+		if !ok {
+			out, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(name)})
+			if err != nil {
+				resolveErr = fmt.Errorf("cannot get AWS secret %s for field '%s': %v", name, path, err)
+				return
+			}
+			secret = aws.ToString(out.SecretString)
+			secrets[name] = secret
+		}
+
+		if key == "" {
+			set(secret)
+			return
+		}
+
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(secret), &values); err != nil {
+			resolveErr = fmt.Errorf("AWS secret %s for field '%s' is not JSON object: %v", name, path, err)
+			return
+		}
+
+		value, ok := values[key]
+		if !ok {
+			resolveErr = fmt.Errorf("AWS secret %s for field '%s' has no key '%s'", name, path, key)
+			return
+		}
+
+		set(fmt.Sprint(value))
+	})
+
+	return resolveErr
+}
+
+func loadConfigAWSSecrets() {
+	if err := resolveAWSSecretsToObject(Config); err != nil {
+		exitOnConfigError(err.Error())
+	}
+}
+
+// usesAWSSecrets returns true if any sensitive field refers to AWS Secrets Manager
+func usesAWSSecrets(conf *ConfigType) bool {
+	found := false
+	walkStringFields(conf, func(path string, get func() string, set func(string)) {
+		if strings.HasPrefix(get(), awsSecretsManagerScheme) && isSecretConfigPath(path) {
+			found = true
+		}
+	})
+	return found
+}
+
+// awsSecretsManagerEndpoint returns host:port of Secrets Manager in the region
+// set by AWS_REGION or AWS_DEFAULT_REGION, or empty string if region is not set.
+func awsSecretsManagerEndpoint() string {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return ""
+	}
+	return "secretsmanager." + region + ".amazonaws.com:443"
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+type mockSecretsManagerClient struct {
+	secrets map[string]string
+	calls   map[string]int
+}
+
+func (c *mockSecretsManagerClient) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	name := aws.ToString(params.SecretId)
+	c.calls[name]++
+
+	secret, ok := c.secrets[name]
+	if !ok {
+		return nil, errors.New("secret not found")
+	}
+
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(secret)}, nil
+}
+
+func mockSecretsManager(t *testing.T, secrets map[string]string) *mockSecretsManagerClient {
+	client := &mockSecretsManagerClient{secrets: secrets, calls: make(map[string]int)}
+
+	newClient := newSecretsManagerClient
+	newSecretsManagerClient = func(ctx context.Context) (secretsManagerClient, error) {
+		return client, nil
+	}
+	t.Cleanup(func() {
+		newSecretsManagerClient = newClient
+	})
+
+	return client
+}
+
+func TestResolveAWSSecrets(t *testing.T) {
+	client := mockSecretsManager(t, map[string]string{
+		"semaphore/db":    `{"password": "db-secret", "user": "semaphore"}`,
+		"semaphore/plain": "plain-secret",
+	})
+
+	conf := ConfigType{
+		EmailPassword:    "aws-sm://semaphore/plain",
+		LdapBindPassword: "aws-sm://semaphore/db#user",
+		WebHost:          "aws-sm://semaphore/db#password",
+		OidcProviders: map[string]OidcProvider{
+			"github": {ClientSecret: "aws-sm://semaphore/db#password"},
+		},
+	}
+	conf.MySQL.Password = "aws-sm://semaphore/db#password"
+
+	if err := resolveAWSSecretsToObject(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.MySQL.Password != "db-secret" || conf.OidcProviders["github"].ClientSecret != "db-secret" ||
+		conf.LdapBindPassword != "semaphore" || conf.EmailPassword != "plain-secret" {
+		t.Errorf("Secrets were not resolved: %+v", conf)
+	}
+
+	if conf.WebHost != "aws-sm://semaphore/db#password" {
+		t.Error("Not sensitive fields must not be resolved")
+	}
+
+	if client.calls["semaphore/db"] != 1 {
+		t.Errorf("Secret must be fetched once, fetched %d times", client.calls["semaphore/db"])
+	}
+}
+
+func TestResolveAWSSecretsErrors(t *testing.T) {
+	mockSecretsManager(t, map[string]string{
+		"semaphore/db": `{"password": "db-secret"}`,
+	})
+
+	for _, ref := range []string{"aws-sm://semaphore/missing", "aws-sm://semaphore/db#user"} {
+		conf := ConfigType{EmailPassword: ref}
+		if resolveAWSSecretsToObject(&conf) == nil {
+			t.Errorf("Invalid reference %s was not rejected", ref)
+		}
+	}
+
+	newSecretsManagerClient = func(ctx context.Context) (secretsManagerClient, error) {
+		t.Error("Client must not be created without aws-sm:// values")
+		return nil, errors.New("unexpected call")
+	}
+
+	if err := resolveAWSSecretsToObject(&ConfigType{EmailPassword: "password"}); err != nil {
+		t.Error(err)
+	}
+}
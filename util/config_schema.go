@@ -0,0 +1,120 @@
+package util
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// configSchemaURL is the JSON Schema draft used by GenerateConfigSchema
+const configSchemaURL = "https://json-schema.org/draft/2020-12/schema"
+
+// configEnumRuleRegex matches rules which list allowed values,
+// like `^mysql|bolt|postgres$` or `^(|gob|json)$`.
+var configEnumRuleRegex = regexp.MustCompile(`^\^\(?([A-Za-z0-9_\-]*(?:\|[A-Za-z0-9_\-]*)+)\)?\$$`)
+
+// GenerateConfigSchema returns JSON Schema of the config file.
+// Rules of string fields are converted to enums or patterns.
+func GenerateConfigSchema() ([]byte, error) {
+	schema := configTypeSchema(reflect.TypeOf(ConfigType{}))
+	schema["$schema"] = configSchemaURL
+	schema["title"] = "Semaphore config"
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// configTypeSchema returns schema of the values of the type
+func configTypeSchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		return map[string]interface{}{"type": "integer"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": configTypeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": configTypeSchema(t.Elem())}
+	case reflect.Struct:
+		return configStructSchema(t)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// configStructSchema returns schema of the struct with properties named by json tags
+func configStructSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldSchema := configTypeSchema(field.Type)
+
+		if rule := field.Tag.Get("rule"); rule != "" && fieldSchema["type"] == "string" {
+			if match := configEnumRuleRegex.FindStringSubmatch(rule); match != nil {
+				fieldSchema["enum"] = strings.Split(match[1], "|")
+			} else {
+				fieldSchema["pattern"] = rule
+			}
+		}
+
+		if defaultVar := field.Tag.Get("default"); defaultVar != "" {
+			if value, ok := configSchemaDefault(fieldSchema["type"], defaultVar); ok {
+				fieldSchema["default"] = value
+			}
+		}
+
+		if envVar := field.Tag.Get("env"); envVar != "" {
+			fieldSchema["description"] = "Environment variable: " + envVar
+		}
+
+		properties[name] = fieldSchema
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// configSchemaDefault converts value of `default` tag to the schema type
+func configSchemaDefault(schemaType interface{}, value string) (interface{}, bool) {
+	switch schemaType {
+	case "string":
+		return value, true
+	case "integer":
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	case "boolean":
+		b, err := castStringToBool(value)
+		return b, err == nil
+	default:
+		return nil, false
+	}
+}
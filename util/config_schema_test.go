@@ -0,0 +1,48 @@
+package util
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateConfigSchema(t *testing.T) {
+	bytes, err := GenerateConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Properties map[string]struct {
+			Type       string                     `json:"type"`
+			Enum       []string                   `json:"enum"`
+			Pattern    string                     `json:"pattern"`
+			Default    interface{}                `json:"default"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"properties"`
+	}
+	if err = json.Unmarshal(bytes, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	dialect, ok := schema.Properties["dialect"]
+	if !ok || dialect.Type != "string" {
+		t.Fatal("Schema must contain dialect")
+	}
+	if !reflect.DeepEqual(dialect.Enum, []string{DbDriverMySQL, DbDriverBolt, DbDriverPostgres}) {
+		t.Errorf("Invalid dialect enum: %v", dialect.Enum)
+	}
+
+	port := schema.Properties["port"]
+	if port.Pattern != "^:?([0-9]{1,5})$" || port.Default != ":3000" {
+		t.Errorf("Invalid port schema: %+v", port)
+	}
+
+	if schema.Properties["max_parallel_tasks"].Type != "integer" || schema.Properties["ldap_needtls"].Type != "boolean" {
+		t.Error("Invalid types of fields")
+	}
+
+	if _, ok = schema.Properties["mysql"].Properties["host"]; !ok {
+		t.Error("Schema must contain nested fields")
+	}
+}
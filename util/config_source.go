@@ -0,0 +1,148 @@
+package util
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Config sources reported by GetConfigSource
+const (
+	ConfigSourceFile        = "file"
+	ConfigSourceSecretsFile = "secrets_file"
+	ConfigSourceDirectory   = "directory"
+	ConfigSourceEnv         = "env"
+	ConfigSourceVault       = "vault"
+	ConfigSourceAWSSecrets  = "aws_secrets_manager"
+	ConfigSourceDefault     = "default"
+)
+
+// configSourceTracer records which loading step last changed each config field.
+// It compares values of the fields before and after each step, so fields set
+// by json decoding are traced the same way as fields set by setConfigValue.
+type configSourceTracer struct {
+	values  map[string]string
+	sources map[string]string
+}
+
+var (
+	configSourcesLock sync.RWMutex
+	configSources     map[string]string
+)
+
+// newConfigSourceTracer returns nil if tracing is not enabled by SEMAPHORE_CONFIG_TRACE
+func newConfigSourceTracer() *configSourceTracer {
+	enabled, err := castStringToBool(os.Getenv("SEMAPHORE_CONFIG_TRACE"))
+	if err != nil || !enabled {
+		return nil
+	}
+
+	return &configSourceTracer{
+		values:  flattenConfigValues(&ConfigType{}),
+		sources: make(map[string]string),
+	}
+}
+
+// record marks fields of conf changed since the previous step as set by source
+func (t *configSourceTracer) record(conf *ConfigType, source string) {
+	if t == nil || conf == nil {
+		return
+	}
+
+	values := flattenConfigValues(conf)
+	for path, value := range values {
+		if t.values[path] != value {
+			t.sources[path] = source
+		}
+	}
+	t.values = values
+}
+
+// apply makes traced sources available by GetConfigSource.
+// Sources of the previous load are cleared if tracing is disabled.
+func (t *configSourceTracer) apply() {
+	configSourcesLock.Lock()
+	defer configSourcesLock.Unlock()
+
+	if t == nil {
+		configSources = nil
+		return
+	}
+
+	configSources = t.sources
+}
+
+// GetConfigSource returns source which last set the config attribute:
+// file, secrets_file, directory, env, vault, aws_secrets_manager or default.
+// Attribute is dot separated path of Go field names or json names, e.g. `mysql.host`.
+// Empty string is returned if the attribute is not set or tracing is disabled.
+func GetConfigSource(attribute string) string {
+	configSourcesLock.RLock()
+	defer configSourcesLock.RUnlock()
+
+	if configSources == nil {
+		return ""
+	}
+
+	return configSources[configFieldGoPath(reflect.TypeOf(ConfigType{}), attribute)]
+}
+
+// configFieldGoPath converts path which may contain json names to path of Go field names
+func configFieldGoPath(t reflect.Type, path string) string {
+	parts := strings.Split(path, ".")
+
+	for i, nested := range parts {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			break
+		}
+
+		found := false
+		for j := 0; j < t.NumField(); j++ {
+			jsonName := strings.Split(t.Field(j).Tag.Get("json"), ",")[0]
+			if t.Field(j).Name == nested || jsonName == nested {
+				parts[i] = t.Field(j).Name
+				t = t.Field(j).Type
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// flattenConfigValues returns json encoded values of conf fields by their paths.
+// Nested structs are flattened, maps and slices are compared as a whole.
+func flattenConfigValues(conf *ConfigType) map[string]string {
+	values := make(map[string]string)
+	flattenConfigValue(reflect.ValueOf(conf).Elem(), "", values)
+	return values
+}
+
+func flattenConfigValue(v reflect.Value, path string, values map[string]string) {
+	if v.Kind() == reflect.Struct {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			flattenConfigValue(v.Field(i), joinConfigPath(path, t.Field(i).Name), values)
+		}
+		return
+	}
+
+	encoded, err := json.Marshal(v.Interface())
+	if err != nil {
+		return
+	}
+	values[path] = string(encoded)
+}
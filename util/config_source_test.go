@@ -0,0 +1,69 @@
+package util
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func initTracedConfig(t *testing.T, content string) {
+	t.Helper()
+
+	for _, name := range []string{
+		"SEMAPHORE_PORT",
+		"SEMAPHORE_COOKIE_HASH",
+		"SEMAPHORE_ACCESS_KEY_ENCRYPTION",
+		"SEMAPHORE_MAX_PARALLEL_TASKS",
+		"SEMAPHORE_LDAP_NEEDTLS",
+		"SEMAPHORE_DB_HOST",
+	} {
+		t.Setenv(name, "")
+		os.Unsetenv(name) //nolint:errcheck
+	}
+	t.Setenv("SEMAPHORE_CONFIG_TRACE", "true")
+
+	configPath := path.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ConfigInit(configPath)
+}
+
+func TestGetConfigSource(t *testing.T) {
+	initTracedConfig(t, `{"dev_mode": true, "dialect": "bolt", "bolt": {"host": "/tmp/database.boltdb"}, "max_parallel_tasks": 3}`)
+
+	if source := GetConfigSource("Port"); source != ConfigSourceDefault {
+		t.Errorf("Expected source of Port to be %q, got %q", ConfigSourceDefault, source)
+	}
+
+	if source := GetConfigSource("max_parallel_tasks"); source != ConfigSourceFile {
+		t.Errorf("Expected source of max_parallel_tasks to be %q, got %q", ConfigSourceFile, source)
+	}
+
+	if source := GetConfigSource("bolt.host"); source != ConfigSourceFile {
+		t.Errorf("Expected source of bolt.host to be %q, got %q", ConfigSourceFile, source)
+	}
+
+	if source := GetConfigSource("email_sender"); source != "" {
+		t.Errorf("Expected unset email_sender to have no source, got %q", source)
+	}
+
+	t.Setenv("SEMAPHORE_PORT", ":4000")
+	ConfigInit(configFilePath)
+
+	if source := GetConfigSource("port"); source != ConfigSourceEnv {
+		t.Errorf("Expected source of port to be %q, got %q", ConfigSourceEnv, source)
+	}
+}
+
+func TestGetConfigSourceDisabled(t *testing.T) {
+	initTracedConfig(t, `{"dev_mode": true, "dialect": "bolt", "bolt": {"host": "/tmp/database.boltdb"}}`)
+
+	t.Setenv("SEMAPHORE_CONFIG_TRACE", "")
+	ConfigInit(configFilePath)
+
+	if source := GetConfigSource("Port"); source != "" {
+		t.Errorf("Expected no source when tracing is disabled, got %q", source)
+	}
+}
@@ -0,0 +1,192 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// VaultConfig describes secrets loaded from HashiCorp Vault
+type VaultConfig struct {
+	Address string `json:"address,omitempty" env:"SEMAPHORE_VAULT_ADDR"`
+	// Token is used for authentication. If it is empty, Semaphore logs in by AppRole.
+	Token    string `json:"token,omitempty" env:"SEMAPHORE_VAULT_TOKEN"`
+	RoleID   string `json:"role_id,omitempty" env:"SEMAPHORE_VAULT_ROLE_ID"`
+	SecretID string `json:"secret_id,omitempty" env:"SEMAPHORE_VAULT_SECRET_ID"`
+	// Secrets maps config field paths like `mysql.pass` to Vault secrets
+	// in format `<path>#<key>`, e.g. `secret/data/semaphore#db_password`.
+	Secrets map[string]string `json:"secrets,omitempty" env:"SEMAPHORE_VAULT_SECRETS"`
+}
+
+// vaultRequestTimeout limits each request to Vault, so Semaphore fails fast
+// if Vault is not reachable.
+const vaultRequestTimeout = 10 * time.Second
+
+// vaultClient reads secrets by Vault HTTP API
+type vaultClient struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+func (c *vaultClient) request(method string, path string, body interface{}) (map[string]interface{}, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), &reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to Vault at %s: %v", c.address, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+
+	var res map[string]interface{}
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("cannot decode Vault response for %s: %v", path, err)
+	}
+
+	return res, nil
+}
+
+// loginByAppRole gets client token by AppRole role_id and secret_id
+func (c *vaultClient) loginByAppRole(roleID string, secretID string) error {
+	res, err := c.request(http.MethodPost, "auth/approle/login", map[string]string{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	})
+	if err != nil {
+		return err
+	}
+
+	auth, _ := res["auth"].(map[string]interface{})
+	token, _ := auth["client_token"].(string)
+	if token == "" {
+		return errors.New("vault AppRole login returned no client token")
+	}
+
+	c.token = token
+	return nil
+}
+
+// readSecret returns key/value data of the secret. Both KV v1 and v2 engines are supported.
+func (c *vaultClient) readSecret(path string) (map[string]interface{}, error) {
+	res, err := c.request(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := res["data"].(map[string]interface{})
+
+	// KV v2 wraps values into data.data next to data.metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("vault secret %s has no data", path)
+	}
+
+	return data, nil
+}
+
+// loadVaultSecretsToObject sets config fields listed in Vault.Secrets
+// to values read from Vault. Each secret path is read once.
+func loadVaultSecretsToObject(conf *ConfigType) error {
+	vault := conf.Vault
+
+	if len(vault.Secrets) == 0 {
+		return nil
+	}
+
+	if vault.Address == "" {
+		return errors.New("value of field 'Vault.Address' is empty: it is required to load vault secrets")
+	}
+
+	client := &vaultClient{
+		address: vault.Address,
+		token:   vault.Token,
+		client:  &http.Client{Timeout: vaultRequestTimeout},
+	}
+
+	if client.token == "" {
+		if vault.RoleID == "" {
+			return errors.New("vault token or AppRole role_id is required to load vault secrets")
+		}
+		if err := client.loginByAppRole(vault.RoleID, vault.SecretID); err != nil {
+			return err
+		}
+	}
+
+	fieldPaths := make([]string, 0, len(vault.Secrets))
+	for fieldPath := range vault.Secrets {
+		fieldPaths = append(fieldPaths, fieldPath)
+	}
+	sort.Strings(fieldPaths)
+
+	secrets := make(map[string]map[string]interface{})
+
+	for _, fieldPath := range fieldPaths {
+		ref := vault.Secrets[fieldPath]
+
+		i := strings.LastIndex(ref, "#")
+		if i <= 0 || i == len(ref)-1 {
+			return fmt.Errorf("vault secret '%s' of field '%s' must have format <path>#<key>", ref, fieldPath)
+		}
+		secretPath, key := ref[:i], ref[i+1:]
+
+		attribute := findConfigField(reflect.ValueOf(conf), fieldPath)
+		if !attribute.IsValid() {
+			return fmt.Errorf("field '%s' set in vault secrets does not exist", fieldPath)
+		}
+
+		data, ok := secrets[secretPath]
+		if !ok {
+			var err error
+			if data, err = client.readSecret(secretPath); err != nil {
+				return err
+			}
+			secrets[secretPath] = data
+		}
+
+		value, ok := data[key]
+		if !ok {
+			return fmt.Errorf("vault secret %s has no key '%s'", secretPath, key)
+		}
+
+		if err := setConfigValue(attribute, value); err != nil {
+			return fmt.Errorf("value of field '%s' from Vault is not valid: %v", fieldPath, err)
+		}
+	}
+
+	return nil
+}
+
+func loadConfigVault() {
+	if err := loadVaultSecretsToObject(Config); err != nil {
+		exitOnConfigError(err.Error())
+	}
+}
@@ -0,0 +1,116 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestVaultServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res interface{}
+
+		switch {
+		case r.URL.Path == "/v1/auth/approle/login" && r.Method == http.MethodPost:
+			var login map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&login); err != nil || login["role_id"] != "role" || login["secret_id"] != "secret-id" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			res = map[string]interface{}{"auth": map[string]interface{}{"client_token": "approle-token"}}
+		case r.Header.Get("X-Vault-Token") != "root-token" && r.Header.Get("X-Vault-Token") != "approle-token":
+			w.WriteHeader(http.StatusForbidden)
+			return
+		case r.URL.Path == "/v1/secret/data/semaphore":
+			res = map[string]interface{}{"data": map[string]interface{}{
+				"data":     map[string]interface{}{"db_password": "db-secret", "tasks": 5},
+				"metadata": map[string]interface{}{"version": 1},
+			}}
+		case r.URL.Path == "/v1/kv/semaphore":
+			res = map[string]interface{}{"data": map[string]interface{}{"telegram_token": "123:token"}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func TestLoadVaultSecrets(t *testing.T) {
+	server := newTestVaultServer(t)
+	defer server.Close()
+
+	conf := ConfigType{
+		Vault: VaultConfig{
+			Address: server.URL,
+			Token:   "root-token",
+			Secrets: map[string]string{
+				"mysql.pass":         "secret/data/semaphore#db_password",
+				"max_parallel_tasks": "secret/data/semaphore#tasks",
+				"TelegramToken":      "kv/semaphore#telegram_token",
+			},
+		},
+	}
+
+	if err := loadVaultSecretsToObject(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.MySQL.Password != "db-secret" || conf.MaxParallelTasks != 5 || conf.TelegramToken != "123:token" {
+		t.Errorf("Secrets were not loaded from Vault: %v, %v, %v", conf.MySQL.Password, conf.MaxParallelTasks, conf.TelegramToken)
+	}
+
+	conf.Vault.Token = ""
+	conf.Vault.RoleID = "role"
+	conf.Vault.SecretID = "secret-id"
+	conf.MySQL.Password = ""
+
+	if err := loadVaultSecretsToObject(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.MySQL.Password != "db-secret" {
+		t.Error("Secrets were not loaded with AppRole login")
+	}
+}
+
+func TestLoadVaultSecretsErrors(t *testing.T) {
+	server := newTestVaultServer(t)
+	defer server.Close()
+
+	if err := loadVaultSecretsToObject(&ConfigType{}); err != nil {
+		t.Error("Vault must be optional: " + err.Error())
+	}
+
+	cases := map[string]VaultConfig{
+		"no address":    {Token: "root-token", Secrets: map[string]string{"mysql.pass": "secret/data/semaphore#db_password"}},
+		"no key":        {Address: server.URL, Token: "root-token", Secrets: map[string]string{"mysql.pass": "secret/data/semaphore"}},
+		"missing key":   {Address: server.URL, Token: "root-token", Secrets: map[string]string{"mysql.pass": "secret/data/semaphore#other"}},
+		"unknown field": {Address: server.URL, Token: "root-token", Secrets: map[string]string{"not_existent": "secret/data/semaphore#db_password"}},
+		"forbidden":     {Address: server.URL, Token: "invalid", Secrets: map[string]string{"mysql.pass": "secret/data/semaphore#db_password"}},
+		"no auth":       {Address: server.URL, Secrets: map[string]string{"mysql.pass": "secret/data/semaphore#db_password"}},
+	}
+
+	for name, vault := range cases {
+		if loadVaultSecretsToObject(&ConfigType{Vault: vault}) == nil {
+			t.Errorf("Invalid Vault config '%s' was not rejected", name)
+		}
+	}
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	err := loadVaultSecretsToObject(&ConfigType{Vault: VaultConfig{
+		Address: unreachable.URL,
+		Token:   "root-token",
+		Secrets: map[string]string{"mysql.pass": "secret/data/semaphore#db_password"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "cannot connect to Vault") {
+		t.Errorf("Unreachable Vault must be reported: %v", err)
+	}
+}
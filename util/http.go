@@ -0,0 +1,35 @@
+package util
+
+import (
+	"net/http"
+	"time"
+)
+
+// userAgentTransport sets User-Agent header of outbound requests.
+// Config is read for each request, so reloaded UserAgent is used.
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", GetConfig().GetUserAgent())
+	return t.base.RoundTrip(req)
+}
+
+// httpClientTimeout limits outbound requests, so unavailable
+// endpoints don't block callers forever
+const httpClientTimeout = 30 * time.Second
+
+// BuildHTTPClient creates HTTP client for outbound requests.
+// If transport is nil, http.DefaultTransport is used.
+func BuildHTTPClient(transport http.RoundTripper) *http.Client {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &http.Client{
+		Transport: &userAgentTransport{base: transport},
+		Timeout:   httpClientTimeout,
+	}
+}
@@ -2,27 +2,145 @@ package util
 
 import (
 	"bytes"
+	"crypto/tls"
+	"errors"
 	log "github.com/Sirupsen/logrus"
-	"io"
+	"net"
 	"net/smtp"
+	"time"
 )
 
-// SendMail dispatches a mail using smtp
-func SendMail(emailHost, mailSender, mailRecipient string, mail bytes.Buffer) error {
-	c, err := smtp.Dial(emailHost)
+// Mailer dispatches mails using smtp. Up to PoolSize idle connections are kept
+// open for KeepAlive and reused by next mails. If KeepAlive is zero, each mail
+// is sent using new connection.
+type Mailer struct {
+	config EmailConfig
+	idle   chan *mailConn
+}
+
+type mailConn struct {
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// NewMailer creates mailer for the email settings
+func NewMailer(config EmailConfig) *Mailer {
+	poolSize := config.PoolSize
+	if poolSize < 1 {
+		poolSize = 1
+	}
+
+	return &Mailer{
+		config: config,
+		idle:   make(chan *mailConn, poolSize),
+	}
+}
+
+// Config returns email settings of the mailer
+func (m *Mailer) Config() EmailConfig {
+	return m.config
+}
+
+// Send dispatches the mail to the recipient.
+// If EmailSecure is set, the mail is sent with authentication and StartTLS.
+func (m *Mailer) Send(mailRecipient string, mail bytes.Buffer) error {
+	client, err := m.getClient()
+	if err != nil {
+		return err
+	}
+
+	err = sendMail(client, m.config.Sender, mailRecipient, mail)
 	if err != nil {
+		// the connection may be in the middle of transaction, so it is not reused
+		if closeErr := client.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
 		return err
 	}
 
-	defer func(c *smtp.Client) {
-		err = c.Close()
-		if err != nil {
-			log.Error(err)
+	m.putClient(client)
+	return nil
+}
+
+// Close closes idle connections
+func (m *Mailer) Close() {
+	for {
+		select {
+		case conn := <-m.idle:
+			quitMailClient(conn.client)
+		default:
+			return
+		}
+	}
+}
+
+// getClient returns idle connection which is still alive or opens new one
+func (m *Mailer) getClient() (*smtp.Client, error) {
+	for {
+		select {
+		case conn := <-m.idle:
+			if time.Since(conn.lastUsed) > m.config.KeepAlive {
+				quitMailClient(conn.client)
+				continue
+			}
+			if err := conn.client.Reset(); err != nil {
+				_ = conn.client.Close()
+				continue
+			}
+			return conn.client, nil
+		default:
+			return m.dial()
+		}
+	}
+}
+
+// putClient returns connection to the pool or closes it if the pool is full
+func (m *Mailer) putClient(client *smtp.Client) {
+	if m.config.KeepAlive <= 0 {
+		quitMailClient(client)
+		return
+	}
+
+	select {
+	case m.idle <- &mailConn{client: client, lastUsed: time.Now()}:
+	default:
+		quitMailClient(client)
+	}
+}
+
+func (m *Mailer) dial() (*smtp.Client, error) {
+	c, err := smtp.Dial(net.JoinHostPort(m.config.Host, m.config.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.config.Secure {
+		return c, nil
+	}
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: m.config.Host}); err != nil {
+			_ = c.Close()
+			return nil, err
 		}
-	}(c)
+	}
+
+	if ok, _ := c.Extension("AUTH"); !ok {
+		_ = c.Close()
+		return nil, errors.New("smtp: server doesn't support AUTH")
+	}
 
+	if err = c.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func sendMail(c *smtp.Client, mailSender, mailRecipient string, mail bytes.Buffer) error {
 	// Set the sender and recipient.
-	err = c.Mail(mailSender)
+	err := c.Mail(mailSender)
 	if err != nil {
 		return err
 	}
@@ -37,31 +155,16 @@ func SendMail(emailHost, mailSender, mailRecipient string, mail bytes.Buffer) er
 		return err
 	}
 
-	defer func(wc io.WriteCloser) {
-		err = wc.Close()
-		if err != nil {
-			log.Error(err)
-		}
-	}(wc)
 	_, err = mail.WriteTo(wc)
-	return err
-}
-
-// SendSecureMail dispatches a mail using smtp with authentication and StartTLS
-func SendSecureMail(emailHost, emailPort, mailSender, mailUsername, mailPassword, mailRecipient string, mail bytes.Buffer) error {
-
-	// Receiver email address.
-	to := []string{
-		mailRecipient,
+	if err != nil {
+		_ = wc.Close()
+		return err
 	}
+	return wc.Close()
+}
 
-	// Authentication.
-	auth := smtp.PlainAuth("", mailUsername, mailPassword, emailHost)
-
-	// Sending email.
-	err := smtp.SendMail(emailHost+":"+emailPort, auth, mailSender, to, mail.Bytes())
-	if err != nil {
-		log.Error(err)
+func quitMailClient(c *smtp.Client) {
+	if err := c.Quit(); err != nil {
+		_ = c.Close()
 	}
-	return err
 }
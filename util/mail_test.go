@@ -0,0 +1,134 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpTestServer is minimal SMTP server which counts connections and received mails
+type smtpTestServer struct {
+	listener    net.Listener
+	lock        sync.Mutex
+	connections int
+	mails       int
+}
+
+func newSMTPTestServer(t *testing.T) *smtpTestServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	srv := &smtpTestServer{listener: listener}
+	go srv.serve()
+	return srv
+}
+
+func (s *smtpTestServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.connections++
+		s.lock.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpTestServer) handle(conn net.Conn) {
+	defer conn.Close() //nolint:errcheck
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch strings.ToUpper(strings.Fields(line + " ")[0]) {
+		case "DATA":
+			reply("354 Start mail input")
+			for {
+				line, err = reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+			}
+			s.lock.Lock()
+			s.mails++
+			s.lock.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpTestServer) stats() (connections int, mails int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connections, s.mails
+}
+
+func (s *smtpTestServer) config(keepAlive time.Duration) EmailConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return EmailConfig{
+		Alert:     true,
+		Sender:    "semaphore@example.com",
+		Host:      host,
+		Port:      port,
+		PoolSize:  1,
+		KeepAlive: keepAlive,
+	}
+}
+
+func TestMailerReusesConnection(t *testing.T) {
+	srv := newSMTPTestServer(t)
+	mailer := NewMailer(srv.config(time.Minute))
+	defer mailer.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := mailer.Send("admin@example.com", *bytes.NewBufferString("Subject: test\r\n\r\ntest")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if connections, mails := srv.stats(); connections != 1 || mails != 3 {
+		t.Errorf("Mails must be sent using one connection, got %d connections and %d mails", connections, mails)
+	}
+}
+
+func TestMailerWithoutKeepAlive(t *testing.T) {
+	srv := newSMTPTestServer(t)
+	mailer := NewMailer(srv.config(0))
+
+	for i := 0; i < 2; i++ {
+		if err := mailer.Send("admin@example.com", *bytes.NewBufferString("Subject: test\r\n\r\ntest")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if connections, mails := srv.stats(); connections != 2 || mails != 2 {
+		t.Errorf("Each mail must be sent using new connection, got %d connections and %d mails", connections, mails)
+	}
+}